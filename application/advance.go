@@ -0,0 +1,77 @@
+package application
+
+import (
+	"fmt"
+)
+
+// advanceTokens is how many recent advance tokens are remembered for each playback client.
+const advanceTokens = 16
+
+var ErrTokenEmpty = fmt.Errorf("advance token is empty")
+
+// advance is the remembered outcome of a successful Advance call so a retried request gets the
+// same answer.
+type advance struct {
+	token string
+	next  VideoDetails
+}
+
+// Advance finishes the video the playback client is playing and returns the next one in a single
+// step. current is the item the player just finished and may be 0 when the player starts. It is
+// only finished if it is still the playing item, so when several players share a playback
+// client only the first to report the end of a video moves the queue on and the others are handed
+// the video that replaced it.
+//
+// token identifies the request. A retried request with the same token returns the result of the
+// first request without changing the playlist again. Failed requests are not remembered since
+// they leave the playlist as it was.
+func (pls *Playlists) Advance(pbc PlaybackClient, current int64, token string) (VideoDetails, error) {
+	if token == "" {
+		return VideoDetails{}, ErrTokenEmpty
+	}
+
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	for _, a := range pls.advances[pbc.ID] {
+		if a.token == token {
+			return a.next, nil
+		}
+	}
+
+	// Fallback videos are never in the playlist so there is nothing to finish.
+	if !pls.dropFallback(pbc, current) && pls.isPlaying(pbc, current) {
+		if err := pls.remove(pbc, current, HistoryFinished); err != nil {
+			return VideoDetails{}, err
+		}
+	}
+
+	next, err := pls.next(pbc)
+	if err != nil {
+		return next, err
+	}
+
+	recent := append(pls.advances[pbc.ID], advance{token: token, next: next})
+	if len(recent) > advanceTokens {
+		recent = recent[len(recent)-advanceTokens:]
+	}
+
+	pls.advances[pbc.ID] = recent
+	return next, nil
+}
+
+// isPlaying reports whether the item is the one the playback client is playing. If nothing is
+// marked as playing, such as after a restart, the first item in the playlist is assumed to be
+// playing. The caller must hold the lock.
+func (pls *Playlists) isPlaying(pbc PlaybackClient, itemID int64) bool {
+	if itemID == 0 {
+		return false
+	}
+
+	if np, ok := pls.playing[pbc.ID]; ok {
+		return np.ItemID == itemID
+	}
+
+	_, pl := pls.active(pbc)
+	return len(pl) > 0 && pl[0].ItemID == itemID
+}
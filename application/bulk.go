@@ -0,0 +1,270 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	// maxBatchVideos caps how many videos a single bulk add or import will queue.
+	maxBatchVideos = 500
+
+	ResultAdded   = "added"
+	ResultMoved   = "moved"
+	ResultSkipped = "skipped"
+	ResultBlocked = "blocked"
+	ResultFailed  = "failed"
+)
+
+// BulkItem is one entry of a bulk add. Video may be a video ID or any URL ParseVideoURL accepts.
+// Times and Loop override the values from the URL when they are set.
+type BulkItem struct {
+	Video        string `json:"video"`
+	StartSeconds int    `json:"start_seconds,omitempty"`
+	EndSeconds   int    `json:"end_seconds,omitempty"`
+	Loop         bool   `json:"loop,omitempty"`
+}
+
+// UnmarshalJSON accepts either a BulkItem object or a plain string holding the video ID or URL.
+func (b *BulkItem) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		*b = BulkItem{}
+		return json.Unmarshal(data, &b.Video)
+	}
+
+	type bulkItem BulkItem
+	return json.Unmarshal(data, (*bulkItem)(b))
+}
+
+// VideoURL parses the item into the video and clip range to queue.
+func (b BulkItem) VideoURL() (VideoURL, error) {
+	v, err := ParseVideoURL(b.Video)
+	if err != nil {
+		return v, err
+	}
+
+	if b.StartSeconds != 0 {
+		v.StartSeconds = b.StartSeconds
+	}
+
+	if b.EndSeconds != 0 {
+		v.EndSeconds = b.EndSeconds
+	}
+
+	v.Loop = v.Loop || b.Loop
+	return v, v.Validate()
+}
+
+// AddResult is the outcome of adding one video as part of a bulk add or import. Row is the
+// 1-based position of the entry in an imported playlist file.
+type AddResult struct {
+	Row     int    `json:"row,omitempty"`
+	Input   string `json:"input,omitempty"`
+	VideoID string `json:"video_id,omitempty"`
+	ItemID  int64  `json:"item_id,omitempty"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// AddBatch adds the items to the playback client's playlist as one block starting at position. A
+// negative position, or one past the end of the playlist, appends the items. In fair and
+// vote-ordered modes appended items are placed as Add places them instead. by identifies who added
+// the videos.
+//
+// Each item is validated and checked against the playlist's duplicate policy, the queue limits,
+// and the filter rules on its own and a result is returned for every item in the order given.
+// Items which fail are left out while the rest are saved in a single transaction. An error is only
+// returned if nothing could be saved.
+func (pls *Playlists) AddBatch(pbc PlaybackClient, items []BulkItem, position int, by Submitter) ([]AddResult, error) {
+	if len(items) > maxBatchVideos {
+		return nil, fmt.Errorf("too many videos: %d is the most that can be added at once", maxBatchVideos)
+	}
+
+	results := make([]AddResult, len(items))
+	details := make([]VideoDetails, len(items))
+	now := time.Now()
+	for i, item := range items {
+		results[i].Input = item.Video
+		v, err := item.VideoURL()
+		if err != nil {
+			results[i].Status = ResultFailed
+			results[i].Error = err.Error()
+			continue
+		}
+
+		details[i] = VideoDetails{
+			VideoID:      v.VideoID,
+			StartSeconds: v.StartSeconds,
+			EndSeconds:   v.EndSeconds,
+			Loop:         v.Loop,
+			AddedAt:      now,
+			AddedBy:      by.Name,
+			AddedFrom:    by.Client,
+		}
+	}
+
+	return pls.addBatch(pbc, details, results, position, false)
+}
+
+// addBatch saves the details whose results have not already failed and fills in the rest of the
+// results. If replace is true every video already in the playlist is removed first, otherwise the
+// details are inserted at position.
+func (pls *Playlists) addBatch(
+	pbc PlaybackClient,
+	details []VideoDetails,
+	results []AddResult,
+	position int,
+	replace bool,
+) ([]AddResult, error) {
+	// Look up metadata before taking the lock. A title or channel the caller filled in, such as one
+	// read from an imported file, can't be trusted by the filter rules so it is replaced.
+	cached := make([]bool, len(details))
+	for i := range details {
+		if results[i].Status == ResultFailed {
+			cached[i] = true
+			continue
+		}
+
+		details[i].Title = ""
+		details[i].AuthorName = ""
+		details[i].ThumbnailURL = ""
+
+		var m VideoMetadata
+		if m, cached[i] = pls.metadata.Cached(details[i].VideoID); cached[i] {
+			m.apply(&details[i])
+		}
+	}
+
+	// The filter rules may have to wait on metadata so they are checked before taking the lock too.
+	for i := range details {
+		if results[i].Status == ResultFailed {
+			continue
+		}
+
+		var err error
+		if cached[i], err = pls.screen(pbc, &details[i], cached[i]); err != nil {
+			results[i].VideoID = details[i].VideoID
+			results[i].Status = ResultBlocked
+			results[i].Error = err.Error()
+		}
+	}
+
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	id, old := pls.active(pbc)
+	if id == "" {
+		return nil, ErrNoActivePlaylist
+	}
+
+	cur := old
+	if replace {
+		cur = nil
+	}
+
+	policy := pls.infos[id].Duplicates
+	usage := newQueueUsage(pls.settingsFor(pbc.ID).QueueLimits, cur)
+	var limitErr error
+	var batch Playlist
+	var slots []int // slots[n] is the index of the result for batch[n]
+	moved := make(map[int64]bool)
+	for i := range details {
+		if results[i].Status == ResultFailed || results[i].Status == ResultBlocked {
+			continue
+		}
+
+		vid := details[i].VideoID
+		results[i].VideoID = vid
+		if policy != DuplicatesAllow && batch.isDuplicate(vid) {
+			results[i].Status = ResultSkipped
+			results[i].Error = fmt.Errorf("%w: %s", ErrVideoQueued, vid).Error()
+			continue
+		}
+
+		d := details[i]
+		results[i].Status = ResultAdded
+		if j := cur.indexOfVideo(vid); j >= 0 {
+			switch policy {
+			case DuplicatesAllow:
+			case DuplicatesMoveToEnd:
+				d = cur[j]
+				moved[d.ItemID] = true
+				results[i].Status = ResultMoved
+			default:
+				results[i].Status = ResultSkipped
+				results[i].Error = fmt.Errorf("%w: %s", ErrVideoQueued, vid).Error()
+				continue
+			}
+		}
+
+		if results[i].Status == ResultAdded {
+			if err := usage.check(d); err != nil {
+				results[i].Status = ResultFailed
+				results[i].Error = err.Error()
+				limitErr = err
+				continue
+			}
+
+			usage.add(d)
+		}
+
+		batch = append(batch, d)
+		slots = append(slots, i)
+	}
+
+	if len(batch) == 0 {
+		// Nothing could be queued because of a limit, so report it rather than an empty success.
+		return results, limitErr
+	}
+
+	// at[j] is the index into batch of pl[j], or -1 for items which were already queued.
+	pl := slices.DeleteFunc(slices.Clone(cur), func(d VideoDetails) bool { return moved[d.ItemID] })
+	at := make([]int, len(pl))
+	for j := range at {
+		at[j] = -1
+	}
+
+	if mode := pls.settingsFor(pbc.ID).Mode; position < 0 && (mode == ModeFair || mode == ModeVoteOrdered) {
+		for n, d := range batch {
+			j := pls.appendIndex(pbc, pl, d)
+			pl = slices.Insert(pl, j, d)
+			at = slices.Insert(at, j, n)
+		}
+	} else {
+		if position < 0 || position > len(pl) {
+			position = len(pl)
+		}
+
+		pl = slices.Insert(pl, position, batch...)
+		for n := range batch {
+			at = slices.Insert(at, position+n, n)
+		}
+	}
+
+	pl, err := pls.db.PlaylistItemAddBatch(id, pl, replace)
+	if err != nil {
+		return nil, fmt.Errorf("Playlists.addBatch: %w", err)
+	}
+
+	for j, n := range at {
+		if n >= 0 {
+			results[slots[n]].ItemID = pl[j].ItemID
+		}
+	}
+
+	pls.set(id, pl)
+	for i := range details {
+		if results[i].Status == ResultAdded && !cached[i] {
+			pls.metadata.Enqueue(results[i].VideoID)
+		}
+	}
+
+	if replace && len(old) > 0 {
+		pls.recordPlayed(pbc, old[0], HistorySkipped)
+	}
+
+	return results, nil
+}
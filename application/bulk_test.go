@@ -0,0 +1,77 @@
+package application
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+// newScreenedPlaylists returns a playlist store whose metadata comes from p and a registered
+// playback client which blocks the "Blocked Channel" channel.
+func newScreenedPlaylists(t *testing.T, p MetadataProvider) (*Playlists, PlaybackClient) {
+	t.Helper()
+
+	pls := NewPlaylists(log.New(io.Discard, "", 0), newTestDB(t), newTestResolver(p, 1, time.Millisecond), NewBroker())
+	pbc, err := pls.Register("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rule, err := NewFilterRule(pbc.ID, FilterChannel, FilterBlock, "Blocked Channel")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pls.AddFilterRule(pbc, rule); err != nil {
+		t.Fatal(err)
+	}
+
+	return pls, pbc
+}
+
+func TestImportPlaylistScreensLookedUpMetadata(t *testing.T) {
+	pls, pbc := newScreenedPlaylists(t, &fakeMetadataProvider{videos: map[string]VideoMetadata{
+		"aaaaaaaaaaa": {Title: "A", AuthorName: "Blocked Channel"},
+		"bbbbbbbbbbb": {Title: "B", AuthorName: "Other Channel"},
+	}})
+
+	// The file claims both videos are from an allowed channel.
+	details := []VideoDetails{
+		{VideoID: "aaaaaaaaaaa", Title: "Safe", AuthorName: "Other Channel"},
+		{VideoID: "bbbbbbbbbbb", Title: "<img src=x onerror=alert(1)>", AuthorName: "Other Channel"},
+	}
+
+	results, err := pls.ImportPlaylist(pbc, details, make([]AddResult, len(details)), false, Submitter{Name: "test"})
+	if err != nil {
+		t.Fatalf("ImportPlaylist: %v", err)
+	}
+
+	if results[0].Status != ResultBlocked {
+		t.Fatalf("got status %q for a video from a blocked channel, want %q", results[0].Status, ResultBlocked)
+	}
+
+	if results[1].Status != ResultAdded {
+		t.Fatalf("got status %q, want %q: %s", results[1].Status, ResultAdded, results[1].Error)
+	}
+
+	pl, _ := pls.Get(pbc)
+	if len(pl) != 1 || pl[0].Title != "B" || pl[0].AuthorName != "Other Channel" {
+		t.Fatalf("got %+v, want the looked up metadata for bbbbbbbbbbb", pl)
+	}
+}
+
+func TestImportPlaylistBlocksUncheckedMetadata(t *testing.T) {
+	// The provider knows nothing, so the file's claims are all there is to go on.
+	pls, pbc := newScreenedPlaylists(t, &fakeMetadataProvider{})
+
+	details := []VideoDetails{{VideoID: "aaaaaaaaaaa", Title: "Safe", AuthorName: "Other Channel"}}
+	results, err := pls.ImportPlaylist(pbc, details, make([]AddResult, len(details)), false, Submitter{Name: "test"})
+	if err != nil {
+		t.Fatalf("ImportPlaylist: %v", err)
+	}
+
+	if results[0].Status != ResultBlocked {
+		t.Fatalf("got status %q, want %q", results[0].Status, ResultBlocked)
+	}
+}
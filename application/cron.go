@@ -0,0 +1,178 @@
+package application
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchLimit is how far ahead CronSpec.Next looks for a matching time. Expressions which can
+// never match, such as the 30th of February, give up once it is reached.
+const cronSearchLimit = 5 * 365 * 24 * time.Hour
+
+var (
+	cronMonths = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+	cronDays = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}
+)
+
+// CronSpec is a parsed five field cron expression: minute, hour, day of month, month, and day of
+// week. Each field holds a bit for every value it matches.
+type CronSpec struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+
+	// As with cron, if both day fields are restricted a day matches when either of them does.
+	domAny bool
+	dowAny bool
+}
+
+// ParseCron parses a cron expression such as "0 7 * * mon-fri". Each field may be *, a value, a
+// range, or a comma separated list of them, and each may have a /step. Months and days of the week
+// may also be given by their three letter names. Sunday is 0 or 7.
+func ParseCron(expr string) (CronSpec, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return CronSpec{}, fmt.Errorf("invalid cron expression %q: expected 5 fields", expr)
+	}
+
+	var c CronSpec
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return c, fmt.Errorf("invalid cron minute: %w", err)
+	}
+
+	if c.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return c, fmt.Errorf("invalid cron hour: %w", err)
+	}
+
+	if c.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return c, fmt.Errorf("invalid cron day of month: %w", err)
+	}
+
+	if c.month, err = parseCronField(fields[3], 1, 12, cronMonths); err != nil {
+		return c, fmt.Errorf("invalid cron month: %w", err)
+	}
+
+	if c.dow, err = parseCronWeekdays(fields[4]); err != nil {
+		return c, fmt.Errorf("invalid cron day of week: %w", err)
+	}
+
+	c.domAny = strings.HasPrefix(fields[2], "*")
+	c.dowAny = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+// parseCronField returns a bit set of the values from first to last which the field matches.
+func parseCronField(field string, first, last int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		expr, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+		}
+
+		lo, hi := first, last
+		if expr != "*" {
+			loStr, hiStr, isRange := strings.Cut(expr, "-")
+			var err error
+			if lo, err = parseCronValue(loStr, first, last, names); err != nil {
+				return 0, err
+			}
+
+			hi = lo
+			if isRange {
+				if hi, err = parseCronValue(hiStr, first, last, names); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// A single value with a step, such as 5/15, runs to the end of the range.
+				hi = last
+			}
+
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range %q", expr)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+
+	return bits, nil
+}
+
+// parseCronWeekdays parses a day of week field into a bit set with Sunday as bit 0.
+func parseCronWeekdays(field string) (uint64, error) {
+	bits, err := parseCronField(field, 0, 7, cronDays)
+	if err != nil {
+		return 0, err
+	}
+
+	// Fold Sunday as 7 into 0.
+	if bits&(1<<7) != 0 {
+		bits = bits&^(1<<7) | 1
+	}
+
+	return bits, nil
+}
+
+func parseCronValue(s string, first, last int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < first || v > last {
+		return 0, fmt.Errorf("invalid value %q: must be between %d and %d", s, first, last)
+	}
+
+	return v, nil
+}
+
+// Next returns the first time after t which matches the expression, in t's location. The zero time
+// is returned if nothing matches within the next five years.
+func (c CronSpec) Next(t time.Time) time.Time {
+	loc := t.Location()
+	limit := t.Add(cronSearchLimit)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<t.Hour()) == 0:
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (c CronSpec) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+
+	return dom || dow
+}
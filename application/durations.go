@@ -0,0 +1,305 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+)
+
+var (
+	// regISODuration matches the ISO 8601 durations the YouTube Data API uses, such as PT4M13S or
+	// P1DT2H.
+	regISODuration = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+	ErrInvalidDuration = fmt.Errorf("invalid duration")
+)
+
+// DurationProvider looks up how long a video is in seconds.
+type DurationProvider interface {
+	Duration(ctx context.Context, vid string) (int, error)
+}
+
+// YouTubeDurationProvider gets video durations from the YouTube Data API. It requires an API key.
+type YouTubeDurationProvider struct {
+	APIKey  string
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewYouTubeDurationProvider creates a new YouTubeDurationProvider. If baseURL is empty the
+// YouTube Data API is used.
+func NewYouTubeDurationProvider(apiKey, baseURL string) *YouTubeDurationProvider {
+	if baseURL == "" {
+		baseURL = DefaultYouTubeAPIURL
+	}
+
+	return &YouTubeDurationProvider{
+		APIKey:  apiKey,
+		BaseURL: baseURL,
+		Client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Duration returns the video's length. Videos the API doesn't know return ErrMetadataNotFound.
+func (p *YouTubeDurationProvider) Duration(ctx context.Context, vid string) (int, error) {
+	q := url.Values{}
+	q.Set("part", "contentDetails")
+	q.Set("id", vid)
+	q.Set("key", p.APIKey)
+
+	body, err := fetch(ctx, p.Client, p.BaseURL+"/videos?"+q.Encode())
+	if err != nil {
+		return 0, fmt.Errorf("YouTubeDurationProvider.Duration: %w", err)
+	}
+
+	var res struct {
+		Items []struct {
+			ContentDetails struct {
+				Duration string `json:"duration"`
+			} `json:"contentDetails"`
+		} `json:"items"`
+	}
+
+	if err := json.Unmarshal(body, &res); err != nil {
+		return 0, fmt.Errorf("YouTubeDurationProvider.Duration: error unmarshalling response body: %w", err)
+	}
+
+	if len(res.Items) == 0 {
+		return 0, fmt.Errorf("YouTubeDurationProvider.Duration: %s: %w", vid, ErrMetadataNotFound)
+	}
+
+	secs, err := parseISODuration(res.Items[0].ContentDetails.Duration)
+	if err != nil {
+		return 0, fmt.Errorf("YouTubeDurationProvider.Duration: %w", err)
+	}
+
+	return secs, nil
+}
+
+// parseISODuration converts an ISO 8601 duration such as PT1H2M3S into seconds.
+func parseISODuration(s string) (int, error) {
+	m := regISODuration.FindStringSubmatch(s)
+	if s == "P" || s == "PT" || m == nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidDuration, s)
+	}
+
+	secs := 0
+	for i, mul := range []int{86400, 3600, 60, 1} {
+		if m[i+1] == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidDuration, s)
+		}
+
+		secs += n * mul
+	}
+
+	return secs, nil
+}
+
+// DurationMetadataProvider is a MetadataProvider which adds the video's duration from a
+// DurationProvider to the metadata from another provider. A failed duration lookup leaves the
+// duration at 0 rather than failing the metadata.
+type DurationMetadataProvider struct {
+	Provider  MetadataProvider
+	Durations DurationProvider
+}
+
+// NewDurationMetadataProvider creates a new DurationMetadataProvider.
+func NewDurationMetadataProvider(provider MetadataProvider, durations DurationProvider) *DurationMetadataProvider {
+	return &DurationMetadataProvider{Provider: provider, Durations: durations}
+}
+
+// Metadata returns the video's metadata with its duration filled in.
+func (p *DurationMetadataProvider) Metadata(ctx context.Context, vid string) (VideoMetadata, error) {
+	m, err := p.Provider.Metadata(ctx, vid)
+	if err != nil {
+		return m, err
+	}
+
+	if secs, err := p.Durations.Duration(ctx, vid); err == nil {
+		m.DurationSeconds = secs
+	}
+
+	return m, nil
+}
+
+// SetDuration records the length of the item's video as reported by a player. Players report the
+// duration the first time they play a video whose duration is not known, so it is only set on
+// items which don't have one. Every queued copy of the video and its cached metadata are updated.
+func (pls *Playlists) SetDuration(pbc PlaybackClient, itemID int64, seconds int) (VideoDetails, error) {
+	if seconds < 1 {
+		return VideoDetails{}, fmt.Errorf("%w: must be 1 second or more", ErrInvalidDuration)
+	}
+
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	id, cur := pls.active(pbc)
+	if id == "" {
+		return VideoDetails{}, ErrNoActivePlaylist
+	}
+
+	i := cur.indexOf(itemID)
+	if i < 0 {
+		return VideoDetails{}, ErrItemNotFound
+	}
+
+	if cur[i].DurationSeconds > 0 {
+		return cur[i], nil
+	}
+
+	vid := cur[i].VideoID
+	if err := pls.db.PlaylistItemSetDuration(vid, seconds); err != nil {
+		return cur[i], fmt.Errorf("Playlists.SetDuration: %w", err)
+	}
+
+	if err := pls.db.MetadataSetDuration(vid, seconds); err != nil {
+		return cur[i], fmt.Errorf("Playlists.SetDuration: %w", err)
+	}
+
+	for id, pl := range pls.lists {
+		if !pl.isDuplicate(vid) {
+			continue
+		}
+
+		pl = slices.Clone(pl)
+		for j := range pl {
+			if pl[j].VideoID == vid && pl[j].DurationSeconds == 0 {
+				pl[j].DurationSeconds = seconds
+			}
+		}
+
+		pls.set(id, pl)
+	}
+
+	d := cur[i]
+	d.DurationSeconds = seconds
+	return d, nil
+}
+
+// ItemETA is how long until a queued video is expected to start. StartsIn and StartsAt are nil if
+// it can't be worked out, such as when a video ahead of it has no known duration or loops.
+type ItemETA struct {
+	ItemID   int64      `json:"item_id"`
+	StartsIn *int       `json:"starts_in_seconds"`
+	StartsAt *time.Time `json:"starts_at"`
+}
+
+// QueueRuntime is when each video in a playback client's queue is expected to start and how long
+// the queue has left to play. TotalSeconds only counts videos whose length is known and Unknown is
+// how many are not. The playing video only counts the time it has left.
+type QueueRuntime struct {
+	Items        []ItemETA `json:"items"`
+	TotalSeconds int       `json:"total_seconds"`
+	Unknown      int       `json:"unknown"`
+}
+
+// QueuedVideo is a video in a playback client's queue and when it is expected to start.
+type QueuedVideo struct {
+	VideoDetails
+	StartsIn *int       `json:"starts_in_seconds"`
+	StartsAt *time.Time `json:"starts_at"`
+}
+
+// QueuedPlaylist is a playback client's playlist with the start ETA of every video and how long
+// the queue has left to play, as in QueueRuntime.
+type QueuedPlaylist struct {
+	Videos       []QueuedVideo `json:"videos"`
+	TotalSeconds int           `json:"total_seconds"`
+	Unknown      int           `json:"unknown"`
+}
+
+// Runtime returns the start ETA of every video in the playback client's playlist, assuming each
+// plays through from now. In shuffle mode only the playing video has an ETA.
+func (pls *Playlists) Runtime(pbc PlaybackClient) (QueueRuntime, error) {
+	pls.mu.RLock()
+	defer pls.mu.RUnlock()
+
+	id, pl := pls.active(pbc)
+	if id == "" {
+		return QueueRuntime{}, ErrNoActivePlaylist
+	}
+
+	return pls.runtime(pbc, pl), nil
+}
+
+// GetQueued returns the playback client's playlist along with the start ETA of every video and the
+// queue's total runtime, worked out as Runtime does. It returns false if the playback client has no
+// active playlist.
+func (pls *Playlists) GetQueued(pbc PlaybackClient) (QueuedPlaylist, bool) {
+	pls.mu.RLock()
+	defer pls.mu.RUnlock()
+
+	id, pl := pls.active(pbc)
+	if id == "" {
+		return QueuedPlaylist{}, false
+	}
+
+	rt := pls.runtime(pbc, pl)
+	q := QueuedPlaylist{
+		Videos:       make([]QueuedVideo, len(pl)),
+		TotalSeconds: rt.TotalSeconds,
+		Unknown:      rt.Unknown,
+	}
+
+	for i, d := range pl {
+		q.Videos[i] = QueuedVideo{
+			VideoDetails: d,
+			StartsIn:     rt.Items[i].StartsIn,
+			StartsAt:     rt.Items[i].StartsAt,
+		}
+	}
+
+	return q, true
+}
+
+// runtime works out the start ETAs and total runtime of pl, the playback client's active playlist.
+// The caller must hold the lock.
+func (pls *Playlists) runtime(pbc PlaybackClient, pl Playlist) QueueRuntime {
+	now := time.Now()
+	mode := pls.settingsFor(pbc.ID).Mode
+	np, playing := pls.playing[pbc.ID]
+
+	rt := QueueRuntime{Items: make([]ItemETA, 0, len(pl))}
+	offset, known := 0, true
+	for i, d := range pl {
+		e := ItemETA{ItemID: d.ItemID}
+		if known && (i == 0 || mode != ModeShuffle) {
+			in := offset
+			at := now.Add(time.Duration(in) * time.Second)
+			e.StartsIn, e.StartsAt = &in, &at
+		}
+
+		rt.Items = append(rt.Items, e)
+
+		length := d.knownDuration()
+		if i == 0 && playing && np.ItemID == d.ItemID && length > 0 {
+			length = max(length-int(now.Sub(np.StartedAt).Seconds()), 0)
+		}
+
+		if length == 0 && d.knownDuration() == 0 {
+			rt.Unknown++
+			known = false
+		}
+
+		rt.TotalSeconds += length
+		offset += length
+
+		// Nothing after a video which repeats until it is removed has an ETA.
+		if d.Loop || (i == 0 && mode == ModeRepeatOne) {
+			known = false
+		}
+	}
+
+	return rt
+}
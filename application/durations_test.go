@@ -0,0 +1,62 @@
+package application
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPlaylistHandlerIncludesRuntime(t *testing.T) {
+	pls, pbc := newTestPlaylists(t)
+
+	var items []int64
+	for i, secs := range []int{120, 60, 0, 30} {
+		d, err := pls.Add(pbc, VideoURL{VideoID: testVideoID(i)}, Submitter{Name: "test"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if secs > 0 {
+			if _, err := pls.SetDuration(pbc, d.ItemID, secs); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		items = append(items, d.ItemID)
+	}
+
+	s := NewHTTPServer(pls.Logger, "", 0, "", "", pls, nil, nil, nil, nil, pls.db)
+	s.AddRoutes()
+
+	w := httptest.NewRecorder()
+	s.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/playlists/"+pbc.ID, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+
+	var q QueuedPlaylist
+	if err := json.NewDecoder(w.Body).Decode(&q); err != nil {
+		t.Fatal(err)
+	}
+
+	if q.TotalSeconds != 210 || q.Unknown != 1 {
+		t.Fatalf("got total %d with %d unknown, want 210 with 1 unknown", q.TotalSeconds, q.Unknown)
+	}
+
+	// Nothing after the video of unknown length has an ETA.
+	want := []*int{ptr(0), ptr(120), ptr(180), nil}
+	for i, v := range q.Videos {
+		if v.ItemID != items[i] {
+			t.Fatalf("video %d is item %d, want %d", i, v.ItemID, items[i])
+		}
+
+		if (v.StartsIn == nil) != (want[i] == nil) || (v.StartsIn != nil && *v.StartsIn != *want[i]) {
+			t.Fatalf("video %d starts in %v, want %v", i, v.StartsIn, want[i])
+		}
+	}
+}
+
+func ptr(n int) *int {
+	return &n
+}
@@ -0,0 +1,89 @@
+package application
+
+import "sync"
+
+const (
+	EventPlaylistUpdated = "playlist_updated"
+
+	eventBufferSize = 16
+)
+
+// Event is sent to clients watching a playback client.
+type Event struct {
+	Type  string `json:"type"`
+	PBCID string `json:"pbc_id"`
+}
+
+// Broker fans out events to every subscriber of a playback client.
+type Broker struct {
+	mu     sync.Mutex
+	subs   map[string]map[chan Event]struct{}
+	closed bool
+}
+
+// NewBroker creates a new Broker.
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[string]map[chan Event]struct{})}
+}
+
+// Subscribe returns a channel that receives every event for the playback client and a func to
+// cancel the subscription. The channel is closed when the subscription is cancelled or the broker
+// is closed.
+func (b *Broker) Subscribe(pbcID string) (<-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Event, eventBufferSize)
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	if b.subs[pbcID] == nil {
+		b.subs[pbcID] = make(map[chan Event]struct{})
+	}
+	b.subs[pbcID][ch] = struct{}{}
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subs[pbcID][ch]; ok {
+			delete(b.subs[pbcID], ch)
+			close(ch)
+		}
+	}
+}
+
+// Publish sends the event to every subscriber of the event's playback client. Publish never
+// blocks; subscribers that are not keeping up miss the event.
+func (b *Broker) Publish(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs[e.PBCID] {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// Close closes every subscriber channel and stops new subscriptions.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for pbcID, chs := range b.subs {
+		for ch := range chs {
+			close(ch)
+		}
+
+		delete(b.subs, pbcID)
+	}
+}
@@ -0,0 +1,422 @@
+package application
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportFormat is a file format a playlist can be exported to and imported from.
+type ExportFormat string
+
+const (
+	FormatJSON ExportFormat = "json"
+	FormatM3U  ExportFormat = "m3u"
+	FormatCSV  ExportFormat = "csv"
+)
+
+var (
+	// csvColumns is the header row of a CSV export. Imports only require the video_id column.
+	csvColumns = []string{
+		"video_id",
+		"title",
+		"author_name",
+		"thumbnail_url",
+		"start_seconds",
+		"end_seconds",
+		"loop",
+		"duration_seconds",
+		"added_at",
+		"added_by",
+	}
+
+	// extinfAttr matches the key="value" attributes of an M3U #EXTINF line.
+	extinfAttr = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9_-]*)="([^"]*)"`)
+)
+
+// ParseExportFormat validates a format name. An empty name is FormatJSON.
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch f := ExportFormat(strings.ToLower(s)); f {
+	case "":
+		return FormatJSON, nil
+	case FormatJSON, FormatM3U, FormatCSV:
+		return f, nil
+	}
+
+	return "", fmt.Errorf("invalid format: %q", s)
+}
+
+// ContentType returns the MIME type of the format.
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case FormatM3U:
+		return "audio/x-mpegurl"
+	case FormatCSV:
+		return "text/csv"
+	}
+
+	return "application/json"
+}
+
+// ExportPlaylist writes the playlist to w in the given format.
+func ExportPlaylist(w io.Writer, f ExportFormat, pl Playlist) error {
+	switch f {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(pl)
+	case FormatM3U:
+		return exportM3U(w, pl)
+	case FormatCSV:
+		return exportCSV(w, pl)
+	}
+
+	return fmt.Errorf("invalid format: %q", f)
+}
+
+// exportM3U writes an extended M3U playlist. The video's start time, end time, and loop flag are
+// kept in the URL and the rest of its details in the #EXTINF line. Videos of unknown length are
+// given the usual -1 duration.
+func exportM3U(w io.Writer, pl Playlist) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "#EXTM3U")
+	for _, d := range pl {
+		duration := -1
+		if d.DurationSeconds > 0 {
+			duration = d.DurationSeconds
+		}
+
+		fmt.Fprintf(
+			bw,
+			"#EXTINF:%d author=\"%s\" thumbnail=\"%s\",%s\n%s\n",
+			duration,
+			m3uAttr(d.AuthorName),
+			m3uAttr(d.ThumbnailURL),
+			strings.Join(strings.Fields(d.Title), " "),
+			videoLink(d),
+		)
+	}
+
+	return bw.Flush()
+}
+
+// m3uAttr makes s safe to use as a quoted #EXTINF attribute value.
+func m3uAttr(s string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(s, `"`, "'")), " ")
+}
+
+// videoLink returns a YouTube URL for the video which ParseVideoURL turns back into the same
+// video, start time, end time, and loop flag.
+func videoLink(d VideoDetails) string {
+	q := url.Values{}
+	q.Set("v", d.VideoID)
+	if d.StartSeconds > 0 {
+		q.Set("t", strconv.Itoa(d.StartSeconds))
+	}
+
+	if d.EndSeconds > 0 {
+		q.Set("end", strconv.Itoa(d.EndSeconds))
+	}
+
+	if d.Loop {
+		q.Set("loop", "1")
+	}
+
+	return "https://www.youtube.com/watch?" + q.Encode()
+}
+
+func exportCSV(w io.Writer, pl Playlist) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvColumns); err != nil {
+		return err
+	}
+
+	for _, d := range pl {
+		err := cw.Write([]string{
+			d.VideoID,
+			d.Title,
+			d.AuthorName,
+			d.ThumbnailURL,
+			strconv.Itoa(d.StartSeconds),
+			strconv.Itoa(d.EndSeconds),
+			strconv.FormatBool(d.Loop),
+			strconv.Itoa(d.DurationSeconds),
+			d.AddedAt.Format(time.RFC3339),
+			d.AddedBy,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// ParsePlaylistFile reads a playlist exported by ExportPlaylist. A result is returned for every
+// entry in the file along with its details. Entries which could not be read are marked as failed
+// and their details should be ignored. An error is only returned if the file itself could not be
+// read.
+func ParsePlaylistFile(r io.Reader, f ExportFormat) ([]VideoDetails, []AddResult, error) {
+	var p playlistFile
+	var err error
+	switch f {
+	case FormatJSON:
+		err = p.readJSON(r)
+	case FormatM3U:
+		err = p.readM3U(r)
+	case FormatCSV:
+		err = p.readCSV(r)
+	default:
+		err = fmt.Errorf("invalid format: %q", f)
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("ParsePlaylistFile: %w", err)
+	}
+
+	return p.details, p.results, nil
+}
+
+// playlistFile collects the entries of a playlist file as it is read.
+type playlistFile struct {
+	details []VideoDetails
+	results []AddResult
+}
+
+// add records the entry. The entry's clip range and video ID are validated unless err is already
+// set.
+func (p *playlistFile) add(input string, d VideoDetails, err error) {
+	if err == nil {
+		v := VideoURL{
+			VideoID:      d.VideoID,
+			StartSeconds: d.StartSeconds,
+			EndSeconds:   d.EndSeconds,
+			Loop:         d.Loop,
+		}
+
+		err = v.Validate()
+	}
+
+	res := AddResult{Row: len(p.results) + 1, Input: input}
+	if err != nil {
+		res.Status = ResultFailed
+		res.Error = err.Error()
+	}
+
+	p.details = append(p.details, d)
+	p.results = append(p.results, res)
+}
+
+func (p *playlistFile) readJSON(r io.Reader) error {
+	var rows []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&rows); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		var d VideoDetails
+		err := json.Unmarshal(row, &d)
+		p.add(d.VideoID, d, err)
+	}
+
+	return nil
+}
+
+func (p *playlistFile) readM3U(r io.Reader) error {
+	var info VideoDetails
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info = parseExtinf(strings.TrimPrefix(line, "#EXTINF:"))
+		case strings.HasPrefix(line, "#"):
+		default:
+			d := info
+			info = VideoDetails{}
+
+			v, err := ParseVideoURL(line)
+			d.VideoID = v.VideoID
+			d.StartSeconds = v.StartSeconds
+			d.EndSeconds = v.EndSeconds
+			d.Loop = v.Loop
+			p.add(line, d, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// parseExtinf reads the duration, title, and attributes from the part of an #EXTINF line after
+// the colon.
+func parseExtinf(s string) VideoDetails {
+	// The title starts after the first comma which is not inside an attribute value.
+	quoted := false
+	split := -1
+	for i, c := range s {
+		if c == '"' {
+			quoted = !quoted
+		}
+
+		if c == ',' && !quoted {
+			split = i
+			break
+		}
+	}
+
+	var d VideoDetails
+	if split < 0 {
+		return d
+	}
+
+	d.Title = strings.TrimSpace(s[split+1:])
+	if f := strings.Fields(s[:split]); len(f) > 0 {
+		if n, err := strconv.Atoi(f[0]); err == nil && n > 0 {
+			d.DurationSeconds = n
+		}
+	}
+
+	for _, m := range extinfAttr.FindAllStringSubmatch(s[:split], -1) {
+		switch m[1] {
+		case "author":
+			d.AuthorName = m[2]
+		case "thumbnail":
+			d.ThumbnailURL = m[2]
+		}
+	}
+
+	return d
+}
+
+func (p *playlistFile) readCSV(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return fmt.Errorf("error reading csv header: %w", err)
+	}
+
+	cols := make(map[string]int)
+	for i, name := range header {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	if _, ok := cols["video_id"]; !ok {
+		return fmt.Errorf("csv header has no video_id column")
+	}
+
+	for {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			var perr *csv.ParseError
+			if !errors.As(err, &perr) {
+				return err
+			}
+
+			p.add("", VideoDetails{}, err)
+			continue
+		}
+
+		d, err := csvDetails(cols, row)
+		p.add(d.VideoID, d, err)
+	}
+}
+
+// csvDetails builds the video's details from a CSV row. Missing columns are left empty.
+func csvDetails(cols map[string]int, row []string) (VideoDetails, error) {
+	get := func(name string) string {
+		if i, ok := cols[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+
+		return ""
+	}
+
+	d := VideoDetails{
+		VideoID:      get("video_id"),
+		Title:        get("title"),
+		AuthorName:   get("author_name"),
+		ThumbnailURL: get("thumbnail_url"),
+		AddedBy:      get("added_by"),
+	}
+
+	var err error
+	if s := get("start_seconds"); s != "" {
+		if d.StartSeconds, err = strconv.Atoi(s); err != nil {
+			return d, fmt.Errorf("invalid start_seconds: %s", s)
+		}
+	}
+
+	if s := get("end_seconds"); s != "" {
+		if d.EndSeconds, err = strconv.Atoi(s); err != nil {
+			return d, fmt.Errorf("invalid end_seconds: %s", s)
+		}
+	}
+
+	if s := get("loop"); s != "" {
+		if d.Loop, err = strconv.ParseBool(s); err != nil {
+			return d, fmt.Errorf("invalid loop: %s", s)
+		}
+	}
+
+	if s := get("duration_seconds"); s != "" {
+		if d.DurationSeconds, err = strconv.Atoi(s); err != nil || d.DurationSeconds < 0 {
+			return d, fmt.Errorf("invalid duration_seconds: %s", s)
+		}
+	}
+
+	if s := get("added_at"); s != "" {
+		if d.AddedAt, err = time.Parse(time.RFC3339, s); err != nil {
+			return d, fmt.Errorf("invalid added_at: %s", s)
+		}
+	}
+
+	return d, nil
+}
+
+// ImportPlaylist adds the entries read by ParsePlaylistFile to the end of the playback client's
+// playlist in a single write. If replace is true the entries replace the whole playlist instead,
+// though a file with no valid entries leaves the playlist as it is. Every entry is credited to by,
+// whoever the file says added it, and entries without an added time are given the current time.
+// The titles and channels in the file are looked up again rather than trusted. The playlist's
+// duplicate policy applies to every entry.
+func (pls *Playlists) ImportPlaylist(
+	pbc PlaybackClient,
+	details []VideoDetails,
+	results []AddResult,
+	replace bool,
+	by Submitter,
+) ([]AddResult, error) {
+	if len(details) > maxBatchVideos {
+		return nil, fmt.Errorf("too many videos: %d is the most that can be added at once", maxBatchVideos)
+	}
+
+	now := time.Now()
+	for i := range details {
+		details[i].ItemID = 0
+		details[i].Upvotes = 0
+		details[i].Downvotes = 0
+		if details[i].AddedAt.IsZero() {
+			details[i].AddedAt = now
+		}
+
+		details[i].AddedBy = by.Name
+		details[i].AddedFrom = by.Client
+	}
+
+	return pls.addBatch(pbc, details, results, -1, replace)
+}
@@ -0,0 +1,91 @@
+package application
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	added := time.Date(2024, 6, 1, 18, 30, 0, 0, time.UTC)
+	pl := Playlist{
+		{
+			VideoID:         "aaaaaaaaaaa",
+			Title:           "A, with a comma",
+			AuthorName:      `Channel "A"`,
+			StartSeconds:    10,
+			EndSeconds:      70,
+			Loop:            true,
+			DurationSeconds: 300,
+			AddedAt:         added,
+			AddedBy:         "alice",
+		},
+		{VideoID: "bbbbbbbbbbb", AddedAt: added, AddedBy: "bob"},
+	}
+
+	for _, f := range []ExportFormat{FormatJSON, FormatM3U, FormatCSV} {
+		var buf bytes.Buffer
+		if err := ExportPlaylist(&buf, f, pl); err != nil {
+			t.Fatalf("%s: ExportPlaylist: %v", f, err)
+		}
+
+		details, results, err := ParsePlaylistFile(&buf, f)
+		if err != nil {
+			t.Fatalf("%s: ParsePlaylistFile: %v", f, err)
+		}
+
+		if len(details) != len(pl) {
+			t.Fatalf("%s: got %d entries, want %d", f, len(details), len(pl))
+		}
+
+		for i, d := range details {
+			if results[i].Status == ResultFailed {
+				t.Fatalf("%s: entry %d failed: %s", f, i, results[i].Error)
+			}
+
+			want := pl[i]
+			if d.VideoID != want.VideoID ||
+				d.StartSeconds != want.StartSeconds ||
+				d.EndSeconds != want.EndSeconds ||
+				d.Loop != want.Loop ||
+				d.DurationSeconds != want.DurationSeconds {
+				t.Fatalf("%s: got %+v, want %+v", f, d, want)
+			}
+		}
+	}
+}
+
+func TestParsePlaylistFileBadDuration(t *testing.T) {
+	csv := "video_id,duration_seconds\naaaaaaaaaaa,-5\nbbbbbbbbbbb,60\n"
+	details, results, err := ParsePlaylistFile(bytes.NewBufferString(csv), FormatCSV)
+	if err != nil {
+		t.Fatalf("ParsePlaylistFile: %v", err)
+	}
+
+	if results[0].Status != ResultFailed {
+		t.Fatalf("got status %q for a negative duration, want %q", results[0].Status, ResultFailed)
+	}
+
+	if results[1].Status == ResultFailed || details[1].DurationSeconds != 60 {
+		t.Fatalf("got %+v, %+v", details[1], results[1])
+	}
+}
+
+func TestImportPlaylistCreditsUploader(t *testing.T) {
+	pls, pbc := newTestPlaylists(t)
+
+	details := []VideoDetails{{VideoID: "aaaaaaaaaaa", AddedBy: "someone else"}}
+	results, err := pls.ImportPlaylist(pbc, details, make([]AddResult, len(details)), false, Submitter{Name: "uploader"})
+	if err != nil {
+		t.Fatalf("ImportPlaylist: %v", err)
+	}
+
+	if results[0].Status != ResultAdded {
+		t.Fatalf("got status %q, want %q: %s", results[0].Status, ResultAdded, results[0].Error)
+	}
+
+	pl, _ := pls.Get(pbc)
+	if len(pl) != 1 || pl[0].AddedBy != "uploader" {
+		t.Fatalf("got %+v, want the video credited to the uploader", pl)
+	}
+}
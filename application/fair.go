@@ -0,0 +1,56 @@
+package application
+
+import "slices"
+
+// fairRounds returns the round each item in the playlist plays in when the submitters take turns.
+// An item's round is one more than the number of items before it from the same submitter.
+func (pl Playlist) fairRounds() []int {
+	seen := make(map[string]int)
+	rounds := make([]int, len(pl))
+	for i, d := range pl {
+		seen[d.AddedBy]++
+		rounds[i] = seen[d.AddedBy]
+	}
+
+	return rounds
+}
+
+// fairIndex returns where a new item from the submitter goes so the submitters take turns. The
+// item is placed after every item in its round or an earlier one, so someone adding a lot of
+// videos waits for everyone else's next video before each of theirs plays.
+func (pl Playlist) fairIndex(by string) int {
+	round := 1
+	for _, d := range pl {
+		if d.AddedBy == by {
+			round++
+		}
+	}
+
+	i := 0
+	for j, r := range pl.fairRounds() {
+		if r <= round {
+			i = j + 1
+		}
+	}
+
+	return i
+}
+
+// fairOrder returns a copy of the playlist ordered so the submitters take turns. Items keep their
+// order within each round, so the first item stays first.
+func (pl Playlist) fairOrder() Playlist {
+	rounds := pl.fairRounds()
+	order := make([]int, len(pl))
+	for i := range order {
+		order[i] = i
+	}
+
+	slices.SortStableFunc(order, func(a, b int) int { return rounds[a] - rounds[b] })
+
+	fair := make(Playlist, len(pl))
+	for i, j := range order {
+		fair[i] = pl[j]
+	}
+
+	return fair
+}
@@ -0,0 +1,186 @@
+package application
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+)
+
+const (
+	// fallbackHistoryLimit is how many of the most recently finished videos FallbackHistory picks
+	// from.
+	fallbackHistoryLimit = 200
+	// fallbackFavoritesLimit is how many of the most played videos FallbackFavorites picks from.
+	fallbackFavoritesLimit = 50
+)
+
+// FallbackSource is where a playback client gets videos from when its queue is empty.
+type FallbackSource string
+
+const (
+	// FallbackNone plays nothing when the queue is empty.
+	FallbackNone FallbackSource = "none"
+	// FallbackPlaylist plays random videos from a saved playlist.
+	FallbackPlaylist FallbackSource = "playlist"
+	// FallbackHistory plays random videos the playback client has played through before.
+	FallbackHistory FallbackSource = "history"
+	// FallbackFavorites plays random videos the playback client has played through more than once,
+	// picking from the ones played the most.
+	FallbackFavorites FallbackSource = "favorites"
+)
+
+// ParseFallbackSource returns the FallbackSource named by s.
+func ParseFallbackSource(s string) (FallbackSource, error) {
+	switch f := FallbackSource(s); f {
+	case FallbackNone, FallbackPlaylist, FallbackHistory, FallbackFavorites:
+		return f, nil
+	}
+
+	return "", fmt.Errorf("invalid fallback source: %q", s)
+}
+
+// fallbackPick is the fallback video handed to a playback client. It is handed out again until the
+// player finishes or skips it, after which done is set so the next pick can avoid the same video.
+type fallbackPick struct {
+	VideoDetails
+	done bool
+}
+
+// FallbackStatus is where a playback client gets videos from when its queue is empty and the
+// fallback video it is playing, if any.
+type FallbackStatus struct {
+	Source     FallbackSource `json:"source"`
+	PlaylistID string         `json:"playlist_id"`
+	Playing    *VideoDetails  `json:"playing"`
+}
+
+// Fallback returns the playback client's fallback source and the fallback video it is playing.
+// The playing video can be skipped by removing its item ID like any queued item.
+func (pls *Playlists) Fallback(pbc PlaybackClient) FallbackStatus {
+	pls.mu.RLock()
+	defer pls.mu.RUnlock()
+
+	st := pls.settingsFor(pbc.ID)
+	fs := FallbackStatus{Source: st.FallbackSource, PlaylistID: st.FallbackPlaylistID}
+	if fb, ok := pls.fallbacks[pbc.ID]; ok && !fb.done {
+		d := fb.VideoDetails
+		fs.Playing = &d
+	}
+
+	return fs
+}
+
+// SetFallback changes where the playback client gets videos from when its queue is empty.
+// playlistID must name a saved playlist when source is FallbackPlaylist and is ignored otherwise.
+func (pls *Playlists) SetFallback(pbc PlaybackClient, source FallbackSource, playlistID string) (PBCSettings, error) {
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	if source != FallbackPlaylist {
+		playlistID = ""
+	} else if _, ok := pls.infos[playlistID]; !ok {
+		return PBCSettings{}, ErrUnknownPlaylist
+	}
+
+	st := pls.settingsFor(pbc.ID)
+	st.FallbackSource = source
+	st.FallbackPlaylistID = playlistID
+	if err := pls.db.SettingsSave(st); err != nil {
+		return st, fmt.Errorf("Playlists.SetFallback: %w", err)
+	}
+
+	pls.settings[pbc.ID] = st
+	delete(pls.fallbacks, pbc.ID)
+	pls.events.Publish(Event{Type: EventPlaylistUpdated, PBCID: pbc.ID})
+
+	return st, nil
+}
+
+// nextFallback returns a video from the playback client's fallback source to play while its queue
+// is empty. The same video is returned until the player is done with it. Fallback videos have
+// Fallback set and a negative ItemID so they can't be mistaken for queued items. Videos the
+// playback client's filter rules block are never picked. The caller must hold the write lock.
+func (pls *Playlists) nextFallback(pbc PlaybackClient) (VideoDetails, error) {
+	last, ok := pls.fallbacks[pbc.ID]
+	if ok && !last.done {
+		return last.VideoDetails, nil
+	}
+
+	candidates, err := pls.fallbackCandidates(pbc)
+	if err != nil {
+		return VideoDetails{}, err
+	}
+
+	f := pls.filtersFor(pbc.ID)
+	candidates = slices.DeleteFunc(candidates, func(d VideoDetails) bool {
+		return f.check(d, d.Title != "") != ""
+	})
+
+	// Avoid playing the same video twice in a row if there is anything else to play.
+	if ok && len(candidates) > 1 {
+		candidates = slices.DeleteFunc(candidates, func(d VideoDetails) bool { return d.VideoID == last.VideoID })
+	}
+
+	if len(candidates) == 0 {
+		return VideoDetails{}, ErrPlaylistEmpty
+	}
+
+	d := candidates[rand.IntN(len(candidates))]
+	d.Loop = false
+	d.Fallback = true
+	pls.fallbacks[pbc.ID] = fallbackPick{VideoDetails: d}
+
+	return d, nil
+}
+
+// fallbackCandidates returns every video the playback client's fallback source could play. The
+// caller must hold the lock.
+func (pls *Playlists) fallbackCandidates(pbc PlaybackClient) ([]VideoDetails, error) {
+	st := pls.settingsFor(pbc.ID)
+	switch st.FallbackSource {
+	case FallbackPlaylist:
+		pl := slices.Clone(pls.lists[st.FallbackPlaylistID])
+		for i := range pl {
+			pl[i].ItemID = -pl[i].ItemID
+		}
+
+		return pl, nil
+	case FallbackHistory, FallbackFavorites:
+		var entries []HistoryEntry
+		var err error
+		if st.FallbackSource == FallbackHistory {
+			entries, err = pls.db.HistoryFinishedList(pbc.ID, fallbackHistoryLimit)
+		} else {
+			entries, err = pls.db.HistoryFavoritesList(pbc.ID, fallbackFavoritesLimit)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Playlists.fallbackCandidates: %w", err)
+		}
+
+		list := make([]VideoDetails, 0, len(entries))
+		for _, e := range entries {
+			d := e.VideoDetails
+			d.ItemID = -e.ID
+			list = append(list, d)
+		}
+
+		return list, nil
+	}
+
+	return nil, nil
+}
+
+// dropFallback marks the playback client's fallback video as done if itemID is its item ID and
+// reports whether it was. Fallback videos are not recorded in the history. The caller must hold
+// the write lock.
+func (pls *Playlists) dropFallback(pbc PlaybackClient, itemID int64) bool {
+	fb, ok := pls.fallbacks[pbc.ID]
+	if !ok || fb.done || fb.ItemID != itemID {
+		return false
+	}
+
+	fb.done = true
+	pls.fallbacks[pbc.ID] = fb
+	return true
+}
@@ -0,0 +1,138 @@
+package application
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// playedThrough records the video in the playback client's history as finished n times.
+func playedThrough(t *testing.T, pls *Playlists, pbc PlaybackClient, vid string, n int) {
+	t.Helper()
+
+	for range n {
+		err := pls.db.HistoryCreate(HistoryEntry{
+			PBCID:        pbc.ID,
+			VideoDetails: VideoDetails{VideoID: vid},
+			StartedAt:    time.Now(),
+			EndedAt:      time.Now(),
+			Status:       HistoryFinished,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFallbackFavorites(t *testing.T) {
+	pls, pbc := newTestPlaylists(t)
+	playedThrough(t, pls, pbc, "aaaaaaaaaaa", 3)
+	playedThrough(t, pls, pbc, "bbbbbbbbbbb", 1)
+
+	if _, err := pls.SetFallback(pbc, FallbackFavorites, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only videos played through more than once are favorites.
+	for range 5 {
+		d, err := pls.GetNext(pbc)
+		if err != nil {
+			t.Fatalf("GetNext: %v", err)
+		}
+
+		if d.VideoID != "aaaaaaaaaaa" || !d.Fallback {
+			t.Fatalf("got %+v, want the favorite as a fallback video", d)
+		}
+
+		if err := pls.Finish(pbc, d.ItemID); err != nil {
+			t.Fatalf("Finish: %v", err)
+		}
+	}
+}
+
+func TestFallbackHandlerSkip(t *testing.T) {
+	pls, pbc := newTestPlaylists(t)
+	playedThrough(t, pls, pbc, "aaaaaaaaaaa", 1)
+	playedThrough(t, pls, pbc, "bbbbbbbbbbb", 1)
+
+	if _, err := pls.SetFallback(pbc, FallbackHistory, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := pls.GetNext(pbc)
+	if err != nil {
+		t.Fatalf("GetNext: %v", err)
+	}
+
+	s := NewHTTPServer(pls.Logger, "", 0, "", "", pls, nil, nil, nil, nil, pls.db)
+	s.AddRoutes()
+
+	fallback := func() FallbackStatus {
+		w := httptest.NewRecorder()
+		s.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/playlists/"+pbc.ID+"/fallback", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", w.Code, w.Body)
+		}
+
+		var fs FallbackStatus
+		if err := json.NewDecoder(w.Body).Decode(&fs); err != nil {
+			t.Fatal(err)
+		}
+
+		return fs
+	}
+
+	fs := fallback()
+	if fs.Source != FallbackHistory || fs.Playing == nil || fs.Playing.ItemID != d.ItemID {
+		t.Fatalf("got %+v, want %s playing item %d", fs, FallbackHistory, d.ItemID)
+	}
+
+	w := httptest.NewRecorder()
+	u := "/playlists/" + pbc.ID + "/items/" + strconv.FormatInt(d.ItemID, 10)
+	s.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, u, nil))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("got status %d removing the fallback video: %s", w.Code, w.Body)
+	}
+
+	if fs := fallback(); fs.Playing != nil {
+		t.Fatalf("fallback video %+v is still playing after it was removed", fs.Playing)
+	}
+
+	next, err := pls.GetNext(pbc)
+	if err != nil {
+		t.Fatalf("GetNext: %v", err)
+	}
+
+	if next.VideoID == d.VideoID {
+		t.Fatalf("got %s again after it was skipped", next.VideoID)
+	}
+}
+
+func TestDeletePlaylistInUseAsFallback(t *testing.T) {
+	pls, pbc := newTestPlaylists(t)
+
+	info, err := pls.CreatePlaylist("radio")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pls.SetFallback(pbc, FallbackPlaylist, info.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pls.DeletePlaylist(info.ID); !errors.Is(err, ErrPlaylistInUse) {
+		t.Fatalf("got %v, want %v", err, ErrPlaylistInUse)
+	}
+
+	if _, err := pls.SetFallback(pbc, FallbackNone, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pls.DeletePlaylist(info.ID); err != nil {
+		t.Fatalf("DeletePlaylist: %v", err)
+	}
+}
@@ -0,0 +1,311 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// filterLookupTimeout is how long adding a video waits on its metadata when a filter rule needs
+// its title or channel.
+const filterLookupTimeout = 5 * time.Second
+
+var (
+	ErrVideoBlocked      = fmt.Errorf("video is blocked")
+	ErrUnknownFilterRule = fmt.Errorf("filter rule not found")
+)
+
+// FilterKind is what part of a video a filter rule matches.
+type FilterKind string
+
+const (
+	// FilterVideo matches a video ID.
+	FilterVideo FilterKind = "video"
+	// FilterChannel matches the video's channel name, ignoring case.
+	FilterChannel FilterKind = "channel"
+	// FilterKeyword matches a word or phrase anywhere in the video's title, ignoring case.
+	FilterKeyword FilterKind = "keyword"
+)
+
+// FilterAction is what happens to a video which matches a filter rule.
+type FilterAction string
+
+const (
+	FilterAllow FilterAction = "allow"
+	FilterBlock FilterAction = "block"
+)
+
+// ParseFilterKind returns the FilterKind named by s.
+func ParseFilterKind(s string) (FilterKind, error) {
+	switch k := FilterKind(s); k {
+	case FilterVideo, FilterChannel, FilterKeyword:
+		return k, nil
+	}
+
+	return "", fmt.Errorf("invalid filter kind: %q", s)
+}
+
+// ParseFilterAction returns the FilterAction named by s.
+func ParseFilterAction(s string) (FilterAction, error) {
+	switch a := FilterAction(s); a {
+	case FilterAllow, FilterBlock:
+		return a, nil
+	}
+
+	return "", fmt.Errorf("invalid filter action: %q", s)
+}
+
+// FilterRule allows or blocks the videos a playback client will queue.
+type FilterRule struct {
+	ID        int64        `json:"id"`
+	PBCID     string       `json:"pbc_id"`
+	Kind      FilterKind   `json:"kind"`
+	Action    FilterAction `json:"action"`
+	Value     string       `json:"value"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// NewFilterRule validates the rule's value and returns a new FilterRule. Video rules take a video
+// ID or any URL ParseVideoURL accepts.
+func NewFilterRule(pbcID string, kind FilterKind, action FilterAction, value string) (FilterRule, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return FilterRule{}, fmt.Errorf("filter value - %w", ErrParamEmpty)
+	}
+
+	if kind == FilterVideo {
+		v, err := BulkItem{Video: value}.VideoURL()
+		if err != nil {
+			return FilterRule{}, err
+		}
+
+		value = v.VideoID
+	}
+
+	return FilterRule{PBCID: pbcID, Kind: kind, Action: action, Value: value, CreatedAt: time.Now()}, nil
+}
+
+// matches reports whether the rule matches the video. Channel and keyword rules never match a
+// video whose metadata is not known.
+func (r FilterRule) matches(d VideoDetails) bool {
+	switch r.Kind {
+	case FilterVideo:
+		return d.VideoID == r.Value
+	case FilterChannel:
+		return d.AuthorName != "" && strings.EqualFold(strings.TrimSpace(d.AuthorName), r.Value)
+	case FilterKeyword:
+		return d.Title != "" && strings.Contains(strings.ToLower(d.Title), strings.ToLower(r.Value))
+	}
+
+	return false
+}
+
+// Filters is a playback client's filter rules. In allowlist-only mode only videos which match an
+// allow rule can be queued.
+type Filters struct {
+	AllowlistOnly bool         `json:"allowlist_only"`
+	Rules         []FilterRule `json:"rules"`
+}
+
+// empty reports whether the filters let every video through.
+func (f Filters) empty() bool {
+	return !f.AllowlistOnly && len(f.Rules) == 0
+}
+
+// needsMetadata reports whether any rule which can decide the video's fate matches on its title
+// or channel. Allow rules only matter in allowlist-only mode.
+func (f Filters) needsMetadata() bool {
+	return slices.ContainsFunc(f.Rules, func(r FilterRule) bool {
+		return r.Kind != FilterVideo && (r.Action == FilterBlock || f.AllowlistOnly)
+	})
+}
+
+// check returns why the video is blocked, or an empty string if it can be queued. Block rules win
+// over allow rules. known is false if the video's metadata could not be looked up, in which case
+// a video which a title or channel rule might have blocked is blocked as well.
+func (f Filters) check(d VideoDetails, known bool) string {
+	for _, r := range f.Rules {
+		if r.Action == FilterBlock && r.matches(d) {
+			return fmt.Sprintf("matches %s rule %q", r.Kind, r.Value)
+		}
+	}
+
+	if !known && f.needsMetadata() {
+		if f.AllowlistOnly && f.allowed(d) {
+			return ""
+		}
+
+		return "its title and channel could not be checked"
+	}
+
+	if f.AllowlistOnly && !f.allowed(d) {
+		return "not on the allowlist"
+	}
+
+	return ""
+}
+
+func (f Filters) allowed(d VideoDetails) bool {
+	return slices.ContainsFunc(f.Rules, func(r FilterRule) bool {
+		return r.Action == FilterAllow && r.matches(d)
+	})
+}
+
+// BlockedVideo is a video which a playback client's filter rules kept out of its queue.
+type BlockedVideo struct {
+	ID           int64     `json:"id"`
+	PBCID        string    `json:"pbc_id"`
+	VideoID      string    `json:"video_id"`
+	Title        string    `json:"title"`
+	AuthorName   string    `json:"author_name"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	AddedBy      string    `json:"added_by"`
+	Reason       string    `json:"reason"`
+	BlockedAt    time.Time `json:"blocked_at"`
+}
+
+// BlockedPage is one page of a playback client's blocked videos, newest first.
+type BlockedPage struct {
+	Entries []BlockedVideo `json:"entries"`
+	Page    int            `json:"page"`
+	PerPage int            `json:"per_page"`
+	Total   int            `json:"total"`
+}
+
+// NewBlockedPage validates the pagination values as NewHistoryPage does and returns an empty
+// BlockedPage.
+func NewBlockedPage(page, perPage int) (BlockedPage, error) {
+	hp, err := NewHistoryPage(page, perPage)
+	if err != nil {
+		return BlockedPage{}, err
+	}
+
+	return BlockedPage{Entries: []BlockedVideo{}, Page: hp.Page, PerPage: hp.PerPage}, nil
+}
+
+// filtersFor returns the playback client's filters. The caller must hold the lock.
+func (pls *Playlists) filtersFor(pbcID string) Filters {
+	return Filters{
+		AllowlistOnly: pls.settingsFor(pbcID).AllowlistOnly,
+		Rules:         slices.Clone(pls.filters[pbcID]),
+	}
+}
+
+// Filters returns the playback client's filter rules in the order they were created.
+func (pls *Playlists) Filters(pbc PlaybackClient) Filters {
+	pls.mu.RLock()
+	defer pls.mu.RUnlock()
+
+	f := pls.filtersFor(pbc.ID)
+	if f.Rules == nil {
+		f.Rules = []FilterRule{}
+	}
+
+	return f
+}
+
+// AddFilterRule saves a new filter rule for the playback client. If the same rule already exists
+// it is returned instead. Videos already queued are not checked against the new rule.
+func (pls *Playlists) AddFilterRule(pbc PlaybackClient, rule FilterRule) (FilterRule, error) {
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	for _, r := range pls.filters[pbc.ID] {
+		same := r.Value == rule.Value || (r.Kind != FilterVideo && strings.EqualFold(r.Value, rule.Value))
+		if r.Kind == rule.Kind && r.Action == rule.Action && same {
+			return r, nil
+		}
+	}
+
+	rule.PBCID = pbc.ID
+	id, err := pls.db.FilterRuleCreate(rule)
+	if err != nil {
+		return rule, fmt.Errorf("Playlists.AddFilterRule: %w", err)
+	}
+
+	rule.ID = id
+	pls.filters[pbc.ID] = append(pls.filters[pbc.ID], rule)
+	return rule, nil
+}
+
+// DeleteFilterRule deletes one of the playback client's filter rules.
+func (pls *Playlists) DeleteFilterRule(pbc PlaybackClient, id int64) error {
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	rules := pls.filters[pbc.ID]
+	i := slices.IndexFunc(rules, func(r FilterRule) bool { return r.ID == id })
+	if i < 0 {
+		return ErrUnknownFilterRule
+	}
+
+	if err := pls.db.FilterRuleDelete(id); err != nil {
+		return fmt.Errorf("Playlists.DeleteFilterRule: %w", err)
+	}
+
+	pls.filters[pbc.ID] = slices.Delete(slices.Clone(rules), i, i+1)
+	return nil
+}
+
+// SetAllowlistOnly turns the playback client's allowlist-only mode on or off.
+func (pls *Playlists) SetAllowlistOnly(pbc PlaybackClient, on bool) (PBCSettings, error) {
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	st := pls.settingsFor(pbc.ID)
+	st.AllowlistOnly = on
+	if err := pls.db.SettingsSave(st); err != nil {
+		return st, fmt.Errorf("Playlists.SetAllowlistOnly: %w", err)
+	}
+
+	pls.settings[pbc.ID] = st
+	return st, nil
+}
+
+// screen checks the video against the playback client's filter rules. If a rule needs the video's
+// title or channel and they are not filled in yet they are looked up first, waiting up to
+// filterLookupTimeout. Blocked videos are recorded so they can be reviewed later and an error
+// wrapping ErrVideoBlocked is returned. screen reports whether d now holds the video's metadata.
+func (pls *Playlists) screen(pbc PlaybackClient, d *VideoDetails, cached bool) (bool, error) {
+	pls.mu.RLock()
+	f := pls.filtersFor(pbc.ID)
+	pls.mu.RUnlock()
+
+	if f.empty() {
+		return cached, nil
+	}
+
+	if !cached && f.needsMetadata() {
+		ctx, cancel := context.WithTimeout(context.Background(), filterLookupTimeout)
+		m, err := pls.metadata.Lookup(ctx, d.VideoID)
+		cancel()
+		if err == nil {
+			m.apply(d)
+			cached = true
+		}
+	}
+
+	reason := f.check(*d, cached)
+	if reason == "" {
+		return cached, nil
+	}
+
+	b := BlockedVideo{
+		PBCID:        pbc.ID,
+		VideoID:      d.VideoID,
+		Title:        d.Title,
+		AuthorName:   d.AuthorName,
+		ThumbnailURL: d.ThumbnailURL,
+		AddedBy:      d.AddedBy,
+		Reason:       reason,
+		BlockedAt:    time.Now(),
+	}
+
+	if err := pls.db.BlockedCreate(b); err != nil {
+		return cached, fmt.Errorf("Playlists.screen: %w", err)
+	}
+
+	return cached, fmt.Errorf("%w: %s", ErrVideoBlocked, reason)
+}
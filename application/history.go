@@ -0,0 +1,63 @@
+package application
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	HistoryFinished = "finished"
+	HistorySkipped  = "skipped"
+
+	historyDefaultPerPage = 25
+	historyMaxPerPage     = 100
+)
+
+// HistoryEntry is a single played video for a playback client. StartedAt is set when the video is
+// first handed to a player and EndedAt when it leaves the queue. Status is HistoryFinished if the
+// player reported that the video played through or HistorySkipped if it was removed early.
+type HistoryEntry struct {
+	ID    int64  `json:"id"`
+	PBCID string `json:"pbc_id"`
+	VideoDetails
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	Status    string    `json:"status"`
+}
+
+// HistoryPage is one page of a playback client's history, newest first.
+type HistoryPage struct {
+	Entries []HistoryEntry `json:"entries"`
+	Page    int            `json:"page"`
+	PerPage int            `json:"per_page"`
+	Total   int            `json:"total"`
+}
+
+// nowPlaying tracks the item a playback client is currently playing so we know when it started
+// once it leaves the queue.
+type nowPlaying struct {
+	ItemID    int64
+	StartedAt time.Time
+}
+
+// NewHistoryPage validates the pagination values and returns an empty HistoryPage. A page or
+// perPage of 0 falls back to the defaults.
+func NewHistoryPage(page, perPage int) (HistoryPage, error) {
+	if page == 0 {
+		page = 1
+	}
+
+	if perPage == 0 {
+		perPage = historyDefaultPerPage
+	}
+
+	if page < 1 {
+		return HistoryPage{}, fmt.Errorf("invalid page: must be 1 or greater")
+	}
+
+	if perPage < 1 || perPage > historyMaxPerPage {
+		return HistoryPage{}, fmt.Errorf("invalid per_page: must be 1 - %d", historyMaxPerPage)
+	}
+
+	return HistoryPage{Entries: []HistoryEntry{}, Page: page, PerPage: perPage}, nil
+}
@@ -0,0 +1,61 @@
+package application
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequeueKeepsClip(t *testing.T) {
+	pls, pbc := newTestPlaylists(t)
+
+	v := VideoURL{VideoID: testVideoID(1), StartSeconds: 10, EndSeconds: 40, Loop: true}
+	d, err := pls.Add(pbc, v, Submitter{Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Skip the clip while it is playing so it is recorded in the history.
+	if _, err := pls.GetNext(pbc); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pls.Remove(pbc, d.ItemID); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := NewHistoryPage(1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	page, err = pls.db.HistoryList(pbc.ID, page)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page.Entries) != 1 {
+		t.Fatalf("got %d history entries, want 1", len(page.Entries))
+	}
+
+	s := NewHTTPServer(pls.Logger, "", 0, "", "", pls, nil, nil, nil, nil, pls.db)
+	s.AddRoutes()
+
+	w := httptest.NewRecorder()
+	u := fmt.Sprintf("/playlists/%s/requeue?entry=%d", pbc.ID, page.Entries[0].ID)
+	s.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, u, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+
+	pl, _ := pls.Get(pbc)
+	if len(pl) != 1 {
+		t.Fatalf("got %d videos queued, want 1", len(pl))
+	}
+
+	got := pl[0]
+	if got.StartSeconds != v.StartSeconds || got.EndSeconds != v.EndSeconds || got.Loop != v.Loop {
+		t.Fatalf("requeued %+v, want the clip %+v", got, v)
+	}
+}
@@ -0,0 +1,154 @@
+package application
+
+import "fmt"
+
+var (
+	ErrQueueFull      = fmt.Errorf("queue is full")
+	ErrSubmitterLimit = fmt.Errorf("too many videos queued by you")
+	ErrQueueDuration  = fmt.Errorf("queue is too long")
+)
+
+// QueueLimits caps what can be queued on a playback client. A limit of 0 is no limit. MaxDuration
+// is in seconds and only counts videos whose length is known.
+type QueueLimits struct {
+	MaxLength       int `json:"max_length"`
+	MaxPerSubmitter int `json:"max_per_submitter"`
+	MaxDuration     int `json:"max_duration_seconds"`
+}
+
+// Validate checks that no limit is negative.
+func (l QueueLimits) Validate() error {
+	if l.MaxLength < 0 || l.MaxPerSubmitter < 0 || l.MaxDuration < 0 {
+		return fmt.Errorf("limits cannot be negative")
+	}
+
+	return nil
+}
+
+// knownDuration returns how long the item plays for in seconds, or 0 if it is not known.
+func (d VideoDetails) knownDuration() int {
+	switch {
+	case d.EndSeconds > 0:
+		return d.EndSeconds - d.StartSeconds
+	case d.DurationSeconds > 0:
+		return max(d.DurationSeconds-d.StartSeconds, 0)
+	}
+
+	return 0
+}
+
+// QueueCapacity is how full a playback client's queue is and how much more it will take from a
+// submitter. A nil remaining value means there is no limit.
+type QueueCapacity struct {
+	QueueLimits
+	Length                int  `json:"length"`
+	Submitted             int  `json:"submitted"`
+	Duration              int  `json:"duration_seconds"`
+	RemainingLength       *int `json:"remaining_length"`
+	RemainingForSubmitter *int `json:"remaining_for_submitter"`
+	RemainingDuration     *int `json:"remaining_duration_seconds"`
+}
+
+// queueUsage tracks what a playlist holds so videos can be checked against the limits as they are
+// added.
+type queueUsage struct {
+	limits    QueueLimits
+	length    int
+	submitted map[string]int
+	duration  int
+}
+
+func newQueueUsage(limits QueueLimits, pl Playlist) *queueUsage {
+	u := &queueUsage{limits: limits, submitted: make(map[string]int)}
+	for _, d := range pl {
+		u.add(d)
+	}
+
+	return u
+}
+
+func (u *queueUsage) add(d VideoDetails) {
+	u.length++
+	u.submitted[d.submitter()]++
+	u.duration += d.knownDuration()
+}
+
+// check returns an error wrapping ErrQueueFull, ErrSubmitterLimit, or ErrQueueDuration if adding
+// the video would go over a limit.
+func (u *queueUsage) check(d VideoDetails) error {
+	l := u.limits
+	if l.MaxLength > 0 && u.length >= l.MaxLength {
+		return fmt.Errorf("%w: the limit is %d videos", ErrQueueFull, l.MaxLength)
+	}
+
+	if l.MaxPerSubmitter > 0 && u.submitted[d.submitter()] >= l.MaxPerSubmitter {
+		return fmt.Errorf("%w: the limit is %d videos each", ErrSubmitterLimit, l.MaxPerSubmitter)
+	}
+
+	if l.MaxDuration > 0 && u.duration+d.knownDuration() > l.MaxDuration {
+		return fmt.Errorf("%w: the limit is %d seconds", ErrQueueDuration, l.MaxDuration)
+	}
+
+	return nil
+}
+
+// remaining returns how far below the limit used is, or nil if there is no limit.
+func remaining(limit, used int) *int {
+	if limit == 0 {
+		return nil
+	}
+
+	n := max(limit-used, 0)
+	return &n
+}
+
+// Capacity returns how full the playback client's queue is and how many more videos it will take
+// from the submitter.
+func (pls *Playlists) Capacity(pbc PlaybackClient, by Submitter) (QueueCapacity, error) {
+	pls.mu.RLock()
+	defer pls.mu.RUnlock()
+
+	id, pl := pls.active(pbc)
+	if id == "" {
+		return QueueCapacity{}, ErrNoActivePlaylist
+	}
+
+	u := newQueueUsage(pls.settingsFor(pbc.ID).QueueLimits, pl)
+	c := QueueCapacity{
+		QueueLimits: u.limits,
+		Length:      u.length,
+		Submitted:   u.submitted[by.key()],
+		Duration:    u.duration,
+	}
+
+	c.RemainingLength = remaining(c.MaxLength, c.Length)
+	c.RemainingForSubmitter = remaining(c.MaxPerSubmitter, c.Submitted)
+	c.RemainingDuration = remaining(c.MaxDuration, c.Duration)
+	if c.RemainingLength != nil && c.RemainingForSubmitter != nil {
+		*c.RemainingForSubmitter = min(*c.RemainingForSubmitter, *c.RemainingLength)
+	}
+
+	return c, nil
+}
+
+// SetLimits changes the playback client's queue limits. Videos already queued are not removed if
+// the queue is over a new limit.
+func (pls *Playlists) SetLimits(pbc PlaybackClient, limits QueueLimits) (PBCSettings, error) {
+	if err := limits.Validate(); err != nil {
+		return PBCSettings{}, err
+	}
+
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	st := pls.settingsFor(pbc.ID)
+	st.QueueLimits = limits
+	if err := pls.db.SettingsSave(st); err != nil {
+		return st, fmt.Errorf("Playlists.SetLimits: %w", err)
+	}
+
+	pls.settings[pbc.ID] = st
+	pls.events.Publish(Event{Type: EventPlaylistUpdated, PBCID: pbc.ID})
+
+	return st, nil
+}
@@ -0,0 +1,49 @@
+package application
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAddHandlerSubmitterLimitByClient(t *testing.T) {
+	pls, pbc := newTestPlaylists(t)
+	if _, err := pls.SetLimits(pbc, QueueLimits{MaxPerSubmitter: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewHTTPServer(pls.Logger, "", 0, "", "", pls, nil, nil, nil, nil, pls.db)
+	s.AddRoutes()
+
+	add := func(vid, addr, by string) int {
+		t.Helper()
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/playlists/"+pbc.ID+"/"+vid+"?by="+by, nil)
+		r.RemoteAddr = addr
+		s.Mux.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	if code := add(testVideoID(1), "192.0.2.1:1234", "alice"); code != http.StatusOK {
+		t.Fatalf("got status %d for the first video, want %d", code, http.StatusOK)
+	}
+
+	// Changing the nickname doesn't give the client more videos.
+	if code := add(testVideoID(2), "192.0.2.1:1234", "bob"); code != http.StatusTooManyRequests {
+		t.Fatalf("got status %d under a new nickname, want %d", code, http.StatusTooManyRequests)
+	}
+
+	if code := add(testVideoID(3), "192.0.2.2:1234", "alice"); code != http.StatusOK {
+		t.Fatalf("got status %d from another client, want %d", code, http.StatusOK)
+	}
+
+	stored, err := pls.db.PlaylistItemList(pbc.PlaylistID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(stored) != 2 || stored[0].AddedFrom != "192.0.2.1" || stored[0].AddedBy != "alice" {
+		t.Fatalf("got %+v, want the client's address saved with each video", stored)
+	}
+}
@@ -0,0 +1,257 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultOEmbedURL = "https://www.youtube.com/oembed"
+
+	metadataQueueSize   = 256
+	metadataMaxAttempts = 5
+	metadataBackoff     = 2 * time.Second
+)
+
+// ErrMetadataNotFound is returned by a MetadataProvider when the video does not exist or cannot be
+// embedded. The resolver does not retry these.
+var ErrMetadataNotFound = fmt.Errorf("video metadata not found")
+
+// VideoMetadata holds the display details for a video. DurationSeconds is 0 unless the provider
+// can look up durations. FetchedAt is set when the metadata is cached.
+type VideoMetadata struct {
+	VideoID         string    `json:"video_id"`
+	Title           string    `json:"title"`
+	AuthorName      string    `json:"author_name"`
+	ThumbnailURL    string    `json:"thumbnail_url"`
+	DurationSeconds int       `json:"duration_seconds"`
+	FetchedAt       time.Time `json:"fetched_at"`
+}
+
+// apply copies the metadata onto the video details. A duration the metadata doesn't have is left
+// as it is.
+func (m VideoMetadata) apply(d *VideoDetails) {
+	d.Title = m.Title
+	d.AuthorName = m.AuthorName
+	d.ThumbnailURL = m.ThumbnailURL
+	if m.DurationSeconds > 0 {
+		d.DurationSeconds = m.DurationSeconds
+	}
+}
+
+// MetadataProvider looks up the metadata for a video.
+type MetadataProvider interface {
+	Metadata(ctx context.Context, vid string) (VideoMetadata, error)
+}
+
+// cachedMetadataProvider is a MetadataProvider which can answer from a local cache without making
+// a network call.
+type cachedMetadataProvider interface {
+	Cached(vid string) (VideoMetadata, bool)
+}
+
+// OEmbedProvider gets video metadata from the YouTube oEmbed endpoint.
+type OEmbedProvider struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewOEmbedProvider creates a new OEmbedProvider. If baseURL is empty the YouTube oEmbed endpoint
+// is used. Any server that answers oEmbed requests, such as a local stub, may be used instead.
+func NewOEmbedProvider(baseURL string) *OEmbedProvider {
+	if baseURL == "" {
+		baseURL = DefaultOEmbedURL
+	}
+
+	return &OEmbedProvider{
+		BaseURL: baseURL,
+		Client:  &http.Client{Timeout: 2 * time.Second},
+	}
+}
+
+// Metadata requests the video's metadata from the oEmbed endpoint.
+func (p *OEmbedProvider) Metadata(ctx context.Context, vid string) (VideoMetadata, error) {
+	m := VideoMetadata{VideoID: vid}
+
+	q := url.Values{}
+	q.Set("format", "json")
+	q.Set("url", "https://www.youtube.com/watch?v="+vid)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.BaseURL+"?"+q.Encode(), nil)
+	if err != nil {
+		return m, fmt.Errorf("OEmbedProvider.Metadata: error creating request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "yt-queuer")
+	res, err := p.Client.Do(req)
+	if err != nil {
+		return m, fmt.Errorf("OEmbedProvider.Metadata: error making request: %w", err)
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusOK:
+	case res.StatusCode == http.StatusNotFound ||
+		res.StatusCode == http.StatusUnauthorized ||
+		res.StatusCode == http.StatusBadRequest:
+		return m, fmt.Errorf("OEmbedProvider.Metadata: %s: %w", vid, ErrMetadataNotFound)
+	default:
+		return m, fmt.Errorf("OEmbedProvider.Metadata: unexpected response: %d", res.StatusCode)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return m, fmt.Errorf("OEmbedProvider.Metadata: error reading response body: %w", err)
+	}
+
+	if err := json.Unmarshal(body, &m); err != nil {
+		return m, fmt.Errorf("OEmbedProvider.Metadata: error unmarshalling response body: %w", err)
+	}
+
+	m.VideoID = vid
+	return m, nil
+}
+
+// MetadataResolver fills in video metadata in the background so adding a video never waits on the
+// network. Failed lookups are retried with an exponential backoff.
+type MetadataResolver struct {
+	Logger      *log.Logger
+	Provider    MetadataProvider
+	MaxAttempts int
+	Backoff     time.Duration
+
+	queue   chan string
+	mu      sync.Mutex
+	pending map[string]struct{}
+}
+
+// NewMetadataResolver creates a new MetadataResolver using the provider.
+func NewMetadataResolver(logger *log.Logger, provider MetadataProvider) *MetadataResolver {
+	return &MetadataResolver{
+		Logger:      logger,
+		Provider:    provider,
+		MaxAttempts: metadataMaxAttempts,
+		Backoff:     metadataBackoff,
+		queue:       make(chan string, metadataQueueSize),
+		pending:     make(map[string]struct{}),
+	}
+}
+
+// Cached returns the video's metadata if the provider has it cached.
+func (mr *MetadataResolver) Cached(vid string) (VideoMetadata, bool) {
+	if mr == nil {
+		return VideoMetadata{}, false
+	}
+
+	c, ok := mr.Provider.(cachedMetadataProvider)
+	if !ok {
+		return VideoMetadata{}, false
+	}
+
+	return c.Cached(vid)
+}
+
+// Lookup asks the provider for the video's metadata and waits for the answer. Unlike a queued
+// lookup it is tried only once.
+func (mr *MetadataResolver) Lookup(ctx context.Context, vid string) (VideoMetadata, error) {
+	if mr == nil {
+		return VideoMetadata{VideoID: vid}, fmt.Errorf("MetadataResolver.Lookup: no metadata provider")
+	}
+
+	m, err := mr.Provider.Metadata(ctx, vid)
+	if err != nil {
+		return m, fmt.Errorf("MetadataResolver.Lookup: %w", err)
+	}
+
+	return m, nil
+}
+
+// Enqueue schedules the video for a metadata lookup. Videos already waiting on a lookup are
+// ignored. Enqueue never blocks; if the queue is full the video is dropped and keeps its bare ID.
+func (mr *MetadataResolver) Enqueue(vid string) {
+	if mr == nil {
+		return
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if _, ok := mr.pending[vid]; ok {
+		return
+	}
+
+	select {
+	case mr.queue <- vid:
+		mr.pending[vid] = struct{}{}
+	default:
+		mr.Logger.Printf("MetadataResolver: queue full, dropping %s\n", vid)
+	}
+}
+
+// Run looks up each queued video and passes the result to resolved until ctx is done. Lookups for
+// different videos run concurrently so one slow video does not hold up the rest.
+func (mr *MetadataResolver) Run(ctx context.Context, resolved func(VideoMetadata) error) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case vid := <-mr.queue:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer mr.done(vid)
+
+				m, err := mr.resolve(ctx, vid)
+				if err != nil {
+					mr.Logger.Printf("MetadataResolver: %v\n", err)
+					return
+				}
+
+				if err := resolved(m); err != nil {
+					mr.Logger.Printf("MetadataResolver: %v\n", err)
+				}
+			}()
+		}
+	}
+}
+
+func (mr *MetadataResolver) done(vid string) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	delete(mr.pending, vid)
+}
+
+// resolve asks the provider for the video's metadata, retrying until MaxAttempts is reached.
+func (mr *MetadataResolver) resolve(ctx context.Context, vid string) (VideoMetadata, error) {
+	wait := mr.Backoff
+	for attempt := 1; ; attempt++ {
+		m, err := mr.Provider.Metadata(ctx, vid)
+		if err == nil {
+			return m, nil
+		}
+
+		if errors.Is(err, ErrMetadataNotFound) || attempt >= mr.MaxAttempts {
+			return m, fmt.Errorf("resolve %s: giving up after %d attempts: %w", vid, attempt, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return m, ctx.Err()
+		case <-time.After(wait):
+		}
+
+		wait *= 2
+	}
+}
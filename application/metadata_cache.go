@@ -0,0 +1,93 @@
+package application
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+const DefaultMetadataTTL = 30 * 24 * time.Hour
+
+// MetadataCache is a MetadataProvider which keeps every lookup in the 'video_metadata' table. The
+// cache is consulted before the wrapped provider is called. Entries older than TTL are fetched
+// again.
+type MetadataCache struct {
+	Logger   *log.Logger
+	DB       *SqliteDB
+	Provider MetadataProvider
+	TTL      time.Duration
+}
+
+// NewMetadataCache creates a new MetadataCache in front of provider. A ttl of 0 uses
+// DefaultMetadataTTL.
+func NewMetadataCache(
+	logger *log.Logger,
+	db *SqliteDB,
+	provider MetadataProvider,
+	ttl time.Duration,
+) *MetadataCache {
+	if ttl <= 0 {
+		ttl = DefaultMetadataTTL
+	}
+
+	return &MetadataCache{Logger: logger, DB: db, Provider: provider, TTL: ttl}
+}
+
+// Cached returns the cached metadata for the video if it has not expired.
+func (c *MetadataCache) Cached(vid string) (VideoMetadata, bool) {
+	m, err := c.DB.MetadataGet(vid)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			c.Logger.Printf("MetadataCache.Cached: %v\n", err)
+		}
+
+		return m, false
+	}
+
+	return m, time.Since(m.FetchedAt) < c.TTL
+}
+
+// Metadata returns the cached metadata for the video or looks it up with the wrapped provider and
+// caches the result.
+func (c *MetadataCache) Metadata(ctx context.Context, vid string) (VideoMetadata, error) {
+	if m, ok := c.Cached(vid); ok {
+		return m, nil
+	}
+
+	return c.Refresh(ctx, vid)
+}
+
+// Refresh looks up the video with the wrapped provider, ignoring any cached entry, and caches the
+// result.
+func (c *MetadataCache) Refresh(ctx context.Context, vid string) (VideoMetadata, error) {
+	m, err := c.Provider.Metadata(ctx, vid)
+	if err != nil {
+		return m, err
+	}
+
+	m.FetchedAt = time.Now()
+	if err := c.DB.MetadataPut(m); err != nil {
+		// The lookup still worked so don't fail it over the cache.
+		c.Logger.Printf("MetadataCache.Refresh: %v\n", err)
+	}
+
+	return m, nil
+}
+
+// Purge removes the video from the cache.
+func (c *MetadataCache) Purge(vid string) error {
+	return c.DB.MetadataDelete(vid)
+}
+
+// PurgeExpired removes every entry older than the TTL from the cache and returns the number of
+// entries removed.
+func (c *MetadataCache) PurgeExpired() (int64, error) {
+	return c.DB.MetadataDeleteBefore(time.Now().Add(-c.TTL))
+}
+
+// PurgeAll removes every entry from the cache and returns the number of entries removed.
+func (c *MetadataCache) PurgeAll() (int64, error) {
+	return c.DB.MetadataDeleteBefore(time.Now())
+}
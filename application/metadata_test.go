@@ -0,0 +1,170 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errTransient = errors.New("temporary failure")
+
+// fakeMetadataProvider is a MetadataProvider which answers from memory. Videos not in the map
+// return ErrMetadataNotFound. The first p.failures calls fail with errTransient.
+type fakeMetadataProvider struct {
+	mu       sync.Mutex
+	videos   map[string]VideoMetadata
+	failures int
+	n        int
+}
+
+func (p *fakeMetadataProvider) Metadata(ctx context.Context, vid string) (VideoMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.n++
+	if p.n <= p.failures {
+		return VideoMetadata{VideoID: vid}, errTransient
+	}
+
+	m, ok := p.videos[vid]
+	if !ok {
+		return VideoMetadata{VideoID: vid}, ErrMetadataNotFound
+	}
+
+	m.VideoID = vid
+	return m, nil
+}
+
+// calls returns how many times Metadata has been called.
+func (p *fakeMetadataProvider) calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.n
+}
+
+func newTestResolver(p MetadataProvider, attempts int, backoff time.Duration) *MetadataResolver {
+	mr := NewMetadataResolver(log.New(io.Discard, "", 0), p)
+	mr.MaxAttempts = attempts
+	mr.Backoff = backoff
+	return mr
+}
+
+func TestMetadataResolverRetries(t *testing.T) {
+	p := &fakeMetadataProvider{
+		videos:   map[string]VideoMetadata{"aaaaaaaaaaa": {Title: "A"}},
+		failures: 2,
+	}
+
+	mr := newTestResolver(p, 3, time.Millisecond)
+	m, err := mr.resolve(context.Background(), "aaaaaaaaaaa")
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+
+	if m.Title != "A" || m.VideoID != "aaaaaaaaaaa" {
+		t.Fatalf("got %+v", m)
+	}
+
+	if p.calls() != 3 {
+		t.Fatalf("got %d calls, want 3", p.calls())
+	}
+}
+
+func TestMetadataResolverGivesUp(t *testing.T) {
+	p := &fakeMetadataProvider{failures: 10}
+
+	mr := newTestResolver(p, 4, time.Millisecond)
+	_, err := mr.resolve(context.Background(), "aaaaaaaaaaa")
+	if !errors.Is(err, errTransient) {
+		t.Fatalf("got %v, want %v", err, errTransient)
+	}
+
+	if p.calls() != 4 {
+		t.Fatalf("got %d calls, want 4", p.calls())
+	}
+}
+
+func TestMetadataResolverNotFoundIsNotRetried(t *testing.T) {
+	p := &fakeMetadataProvider{}
+
+	mr := newTestResolver(p, 5, time.Millisecond)
+	_, err := mr.resolve(context.Background(), "aaaaaaaaaaa")
+	if !errors.Is(err, ErrMetadataNotFound) {
+		t.Fatalf("got %v, want %v", err, ErrMetadataNotFound)
+	}
+
+	if p.calls() != 1 {
+		t.Fatalf("got %d calls, want 1", p.calls())
+	}
+}
+
+func TestMetadataResolverBackoff(t *testing.T) {
+	p := &fakeMetadataProvider{
+		videos:   map[string]VideoMetadata{"aaaaaaaaaaa": {Title: "A"}},
+		failures: 2,
+	}
+
+	// Waits 20ms and then 40ms before the third attempt.
+	mr := newTestResolver(p, 3, 20*time.Millisecond)
+	start := time.Now()
+	if _, err := mr.resolve(context.Background(), "aaaaaaaaaaa"); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+
+	if took := time.Since(start); took < 60*time.Millisecond {
+		t.Fatalf("resolved after %v, want at least 60ms", took)
+	}
+}
+
+func TestMetadataResolverStopsWaitingOnCancel(t *testing.T) {
+	p := &fakeMetadataProvider{failures: 10}
+
+	mr := newTestResolver(p, 5, time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := mr.resolve(ctx, "aaaaaaaaaaa"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestMetadataResolverRun(t *testing.T) {
+	p := &fakeMetadataProvider{
+		videos:   map[string]VideoMetadata{"aaaaaaaaaaa": {Title: "A"}},
+		failures: 1,
+	}
+
+	mr := newTestResolver(p, 3, time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// A video which is already waiting on a lookup is only looked up once.
+	mr.Enqueue("aaaaaaaaaaa")
+	mr.Enqueue("aaaaaaaaaaa")
+
+	got := make(chan VideoMetadata, 2)
+	go mr.Run(ctx, func(m VideoMetadata) error {
+		got <- m
+		return nil
+	})
+
+	select {
+	case m := <-got:
+		if m.Title != "A" {
+			t.Fatalf("got %+v", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("metadata was not resolved")
+	}
+
+	select {
+	case m := <-got:
+		t.Fatalf("video resolved twice: %+v", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
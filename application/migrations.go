@@ -0,0 +1,642 @@
+package application
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const tb_schema_migrations = "schema_migrations"
+
+var ErrSchemaTooNew = fmt.Errorf("database schema is newer than this version of ytqueuer")
+
+// Migration is a single, numbered change to the database schema. Migrations are applied in order
+// and each one runs inside its own transaction along with the schema_migrations record for it, so
+// a failed migration leaves the database at the previous version.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, tx *sql.Tx) error
+}
+
+// MigrationStatus reports whether a migration has been applied. Err is only set by a dry run.
+type MigrationStatus struct {
+	Version     int       `json:"version"`
+	Description string    `json:"description"`
+	Applied     bool      `json:"applied"`
+	AppliedAt   time.Time `json:"applied_at"`
+	Err         error     `json:"-"`
+}
+
+// Migrations is the ordered list of schema migrations. New migrations must be appended with the
+// next version number. Never edit or reorder a migration once it has been released.
+var Migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create playlists, cec, and wol tables",
+		Up: execMigration(`
+		CREATE TABLE IF NOT EXISTS ` + tb_playlists + ` (
+			id VARCHAR(11) NOT NULL PRIMARY KEY,
+			name VARCHAR(32) NOT NULL,
+			playlist TEXT NOT NULL DEFAULT '[]'
+		);
+		CREATE INDEX IF NOT EXISTS idx_playlists_id ON ` + tb_playlists + ` (id);
+
+		CREATE TABLE IF NOT EXISTS ` + tb_cec + ` (
+			pbc_id VARCHAR(11) NOT NULL PRIMARY KEY,
+			alias VARCHAR(14) NOT NULL,
+			device VARCHAR(14) NOT NULL,
+			logical_addr VARCHAR(7) NOT NULL,
+			physical_addr VARCHAR(7) NOT NULL,
+			FOREIGN KEY (pbc_id) REFERENCES ` + tb_playlists + `(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS idx_cec_pbc_id ON ` + tb_cec + ` (pbc_id);
+
+		CREATE TABLE IF NOT EXISTS ` + tb_wol + ` (
+			pbc_id VARCHAR(11) NOT NULL PRIMARY KEY,
+			alias VARCHAR(255) NOT NULL,
+			mac VARCHAR(17) NOT NULL,
+			interface VARCHAR(15) NOT NULL,
+			port INTEGER NOT NULL DEFAULT 9,
+			enabled BOOLEAN NOT NULL DEFAULT 1,
+			FOREIGN KEY (pbc_id) REFERENCES ` + tb_playlists + `(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS idx_wol_pbc_id ON ` + tb_wol + ` (pbc_id);
+		CREATE INDEX IF NOT EXISTS idx_wol_mac ON ` + tb_wol + ` (mac);`),
+	},
+	{
+		Version:     2,
+		Description: "create history table",
+		Up: execMigration(`
+		CREATE TABLE IF NOT EXISTS ` + tb_history + ` (
+			id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			pbc_id VARCHAR(11) NOT NULL,
+			video_id VARCHAR(11) NOT NULL,
+			title TEXT NOT NULL DEFAULT '',
+			author_name TEXT NOT NULL DEFAULT '',
+			thumbnail_url TEXT NOT NULL DEFAULT '',
+			start_seconds INTEGER NOT NULL DEFAULT 0,
+			started_at TIMESTAMP NOT NULL,
+			ended_at TIMESTAMP NOT NULL,
+			status VARCHAR(8) NOT NULL,
+			FOREIGN KEY (pbc_id) REFERENCES ` + tb_playlists + `(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS idx_history_pbc_id ON ` + tb_history + ` (pbc_id, ended_at);`),
+	},
+	{
+		Version:     3,
+		Description: "create playlist_items table and convert json playlists",
+		Up: func(ctx context.Context, tx *sql.Tx) error {
+			query := `
+			CREATE TABLE IF NOT EXISTS ` + tb_playlist_items + ` (
+				id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+				pbc_id VARCHAR(11) NOT NULL,
+				position INTEGER NOT NULL,
+				added_at TIMESTAMP NOT NULL,
+				added_by VARCHAR(64) NOT NULL DEFAULT '',
+				video_id VARCHAR(11) NOT NULL,
+				title TEXT NOT NULL DEFAULT '',
+				author_name TEXT NOT NULL DEFAULT '',
+				thumbnail_url TEXT NOT NULL DEFAULT '',
+				start_seconds INTEGER NOT NULL DEFAULT 0,
+				FOREIGN KEY (pbc_id) REFERENCES ` + tb_playlists + `(id) ON DELETE CASCADE
+			);
+			CREATE INDEX IF NOT EXISTS idx_playlist_items_pbc_id ON ` + tb_playlist_items + ` (pbc_id, position);`
+			if _, err := tx.ExecContext(ctx, query); err != nil {
+				return err
+			}
+
+			return convertJSONPlaylists(ctx, tx)
+		},
+	},
+	{
+		Version:     4,
+		Description: "store cec.logical_addr as INTEGER",
+		Up: execMigration(`
+		CREATE TABLE ` + tb_cec + `_new (
+			pbc_id VARCHAR(11) NOT NULL PRIMARY KEY,
+			alias VARCHAR(14) NOT NULL,
+			device VARCHAR(14) NOT NULL,
+			logical_addr INTEGER NOT NULL,
+			physical_addr VARCHAR(7) NOT NULL,
+			FOREIGN KEY (pbc_id) REFERENCES ` + tb_playlists + `(id) ON DELETE CASCADE
+		);
+		INSERT INTO ` + tb_cec + `_new (pbc_id, alias, device, logical_addr, physical_addr)
+			SELECT pbc_id, alias, device, CAST(logical_addr AS INTEGER), physical_addr FROM ` + tb_cec + `;
+		DROP TABLE ` + tb_cec + `;
+		ALTER TABLE ` + tb_cec + `_new RENAME TO ` + tb_cec + `;
+		CREATE INDEX IF NOT EXISTS idx_cec_pbc_id ON ` + tb_cec + ` (pbc_id);`),
+	},
+	{
+		Version:     5,
+		Description: "drop playlists.playlist json column",
+		Up:          execMigration(`ALTER TABLE ` + tb_playlists + ` DROP COLUMN playlist;`),
+	},
+	{
+		Version:     6,
+		Description: "create video_metadata table",
+		Up: execMigration(`
+		CREATE TABLE IF NOT EXISTS ` + tb_video_metadata + ` (
+			video_id VARCHAR(11) NOT NULL PRIMARY KEY,
+			title TEXT NOT NULL DEFAULT '',
+			author_name TEXT NOT NULL DEFAULT '',
+			thumbnail_url TEXT NOT NULL DEFAULT '',
+			fetched_at TIMESTAMP NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_video_metadata_fetched_at ON ` + tb_video_metadata + ` (fetched_at);`),
+	},
+	{
+		Version:     7,
+		Description: "create pbc_settings table",
+		Up: execMigration(`
+		CREATE TABLE IF NOT EXISTS ` + tb_pbc_settings + ` (
+			pbc_id VARCHAR(12) NOT NULL PRIMARY KEY,
+			mode TEXT NOT NULL DEFAULT 'normal',
+			FOREIGN KEY (pbc_id) REFERENCES ` + tb_playlists + `(id) ON DELETE CASCADE
+		);`),
+	},
+	{
+		// Up to now the 'playlists' table held the playback clients, each with exactly one
+		// playlist. Renaming it to 'pbcs' keeps every foreign key pointing at the playback client.
+		// Each playback client then gets a playlist with the same ID and name which becomes its
+		// active playlist.
+		Version:     8,
+		Description: "decouple playlists from playback clients",
+		Up: execMigration(`
+		ALTER TABLE ` + tb_playlists + ` RENAME TO ` + tb_pbcs + `;
+		DROP INDEX IF EXISTS idx_playlists_id;
+
+		CREATE TABLE ` + tb_playlists + ` (
+			id VARCHAR(12) NOT NULL PRIMARY KEY,
+			name VARCHAR(64) NOT NULL,
+			created_at TIMESTAMP NOT NULL
+		);
+		INSERT INTO ` + tb_playlists + ` (id, name, created_at)
+			SELECT id, name, CURRENT_TIMESTAMP FROM ` + tb_pbcs + `;
+
+		ALTER TABLE ` + tb_pbcs + ` ADD COLUMN playlist_id VARCHAR(12)
+			REFERENCES ` + tb_playlists + `(id) ON DELETE SET NULL;
+		UPDATE ` + tb_pbcs + ` SET playlist_id = id;
+
+		CREATE TABLE ` + tb_playlist_items + `_new (
+			id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			playlist_id VARCHAR(12) NOT NULL,
+			position INTEGER NOT NULL,
+			added_at TIMESTAMP NOT NULL,
+			added_by VARCHAR(64) NOT NULL DEFAULT '',
+			video_id VARCHAR(11) NOT NULL,
+			title TEXT NOT NULL DEFAULT '',
+			author_name TEXT NOT NULL DEFAULT '',
+			thumbnail_url TEXT NOT NULL DEFAULT '',
+			start_seconds INTEGER NOT NULL DEFAULT 0,
+			FOREIGN KEY (playlist_id) REFERENCES ` + tb_playlists + `(id) ON DELETE CASCADE
+		);
+		INSERT INTO ` + tb_playlist_items + `_new (id, playlist_id, position, added_at, added_by,
+			video_id, title, author_name, thumbnail_url, start_seconds)
+			SELECT id, pbc_id, position, added_at, added_by, video_id, title, author_name,
+				thumbnail_url, start_seconds FROM ` + tb_playlist_items + `;
+		DROP TABLE ` + tb_playlist_items + `;
+		ALTER TABLE ` + tb_playlist_items + `_new RENAME TO ` + tb_playlist_items + `;
+		CREATE INDEX IF NOT EXISTS idx_playlist_items_playlist_id
+			ON ` + tb_playlist_items + ` (playlist_id, position);`),
+	},
+	{
+		Version:     9,
+		Description: "add clip end and loop to playlist_items",
+		Up: execMigration(`
+		ALTER TABLE ` + tb_playlist_items + ` ADD COLUMN end_seconds INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE ` + tb_playlist_items + ` ADD COLUMN loop BOOLEAN NOT NULL DEFAULT 0;`),
+	},
+	{
+		Version:     10,
+		Description: "add duplicate policy to playlists",
+		Up: execMigration(`
+		ALTER TABLE ` + tb_playlists + ` ADD COLUMN duplicates TEXT NOT NULL DEFAULT 'reject';`),
+	},
+	{
+		Version:     11,
+		Description: "create trash table",
+		Up: execMigration(`
+		CREATE TABLE IF NOT EXISTS ` + tb_trash + ` (
+			id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			batch INTEGER NOT NULL,
+			pbc_id VARCHAR(12) NOT NULL,
+			playlist_id VARCHAR(12) NOT NULL,
+			position INTEGER NOT NULL,
+			item_id INTEGER NOT NULL,
+			added_at TIMESTAMP NOT NULL,
+			added_by VARCHAR(64) NOT NULL DEFAULT '',
+			video_id VARCHAR(11) NOT NULL,
+			title TEXT NOT NULL DEFAULT '',
+			author_name TEXT NOT NULL DEFAULT '',
+			thumbnail_url TEXT NOT NULL DEFAULT '',
+			start_seconds INTEGER NOT NULL DEFAULT 0,
+			end_seconds INTEGER NOT NULL DEFAULT 0,
+			loop BOOLEAN NOT NULL DEFAULT 0,
+			reason TEXT NOT NULL,
+			deleted_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (pbc_id) REFERENCES ` + tb_pbcs + `(id) ON DELETE CASCADE,
+			FOREIGN KEY (playlist_id) REFERENCES ` + tb_playlists + `(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS idx_trash_pbc_id ON ` + tb_trash + ` (pbc_id, batch);
+		CREATE INDEX IF NOT EXISTS idx_trash_deleted_at ON ` + tb_trash + ` (deleted_at);`),
+	},
+	{
+		Version:     12,
+		Description: "create schedules table",
+		Up: execMigration(`
+		CREATE TABLE IF NOT EXISTS ` + tb_schedules + ` (
+			id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			name VARCHAR(64) NOT NULL DEFAULT '',
+			pbc_id VARCHAR(12) NOT NULL,
+			cron TEXT NOT NULL DEFAULT '',
+			run_at TIMESTAMP,
+			time_zone TEXT NOT NULL,
+			action TEXT NOT NULL,
+			video_id VARCHAR(11) NOT NULL DEFAULT '',
+			start_seconds INTEGER NOT NULL DEFAULT 0,
+			end_seconds INTEGER NOT NULL DEFAULT 0,
+			loop BOOLEAN NOT NULL DEFAULT 0,
+			playlist_id VARCHAR(12) NOT NULL DEFAULT '',
+			missed TEXT NOT NULL DEFAULT 'skip',
+			enabled BOOLEAN NOT NULL DEFAULT 1,
+			next_run TIMESTAMP,
+			last_run TIMESTAMP,
+			last_error TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (pbc_id) REFERENCES ` + tb_pbcs + `(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS idx_schedules_pbc_id ON ` + tb_schedules + ` (pbc_id);`),
+	},
+	{
+		// Votes go with their item when it is removed from the queue.
+		Version:     13,
+		Description: "create votes table",
+		Up: execMigration(`
+		CREATE TABLE IF NOT EXISTS ` + tb_votes + ` (
+			item_id INTEGER NOT NULL,
+			voter VARCHAR(64) NOT NULL,
+			vote INTEGER NOT NULL,
+			voted_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (item_id, voter),
+			FOREIGN KEY (item_id) REFERENCES ` + tb_playlist_items + `(id) ON DELETE CASCADE
+		);`),
+	},
+	{
+		Version:     14,
+		Description: "add queue limits to pbc_settings",
+		Up: execMigration(`
+		ALTER TABLE ` + tb_pbc_settings + ` ADD COLUMN max_length INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE ` + tb_pbc_settings + ` ADD COLUMN max_per_submitter INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE ` + tb_pbc_settings + ` ADD COLUMN max_duration INTEGER NOT NULL DEFAULT 0;`),
+	},
+	{
+		Version:     15,
+		Description: "create filter_rules and blocked_videos tables",
+		Up: execMigration(`
+		CREATE TABLE IF NOT EXISTS ` + tb_filter_rules + ` (
+			id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			pbc_id VARCHAR(12) NOT NULL,
+			kind TEXT NOT NULL,
+			action TEXT NOT NULL,
+			value TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (pbc_id) REFERENCES ` + tb_pbcs + `(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS idx_filter_rules_pbc_id ON ` + tb_filter_rules + ` (pbc_id);
+
+		CREATE TABLE IF NOT EXISTS ` + tb_blocked_videos + ` (
+			id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			pbc_id VARCHAR(12) NOT NULL,
+			video_id VARCHAR(11) NOT NULL,
+			title TEXT NOT NULL DEFAULT '',
+			author_name TEXT NOT NULL DEFAULT '',
+			thumbnail_url TEXT NOT NULL DEFAULT '',
+			added_by VARCHAR(64) NOT NULL DEFAULT '',
+			reason TEXT NOT NULL,
+			blocked_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (pbc_id) REFERENCES ` + tb_pbcs + `(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS idx_blocked_videos_pbc_id ON ` + tb_blocked_videos + ` (pbc_id, blocked_at);
+
+		ALTER TABLE ` + tb_pbc_settings + ` ADD COLUMN allowlist_only BOOLEAN NOT NULL DEFAULT 0;`),
+	},
+	{
+		Version:     16,
+		Description: "create playback_windows table",
+		Up: execMigration(`
+		CREATE TABLE IF NOT EXISTS ` + tb_windows + ` (
+			id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			pbc_id VARCHAR(12) NOT NULL,
+			days TEXT NOT NULL DEFAULT '*',
+			start_time VARCHAR(5) NOT NULL,
+			end_time VARCHAR(5) NOT NULL,
+			time_zone TEXT NOT NULL,
+			standby BOOLEAN NOT NULL DEFAULT 0,
+			created_at TIMESTAMP NOT NULL,
+			FOREIGN KEY (pbc_id) REFERENCES ` + tb_pbcs + `(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS idx_playback_windows_pbc_id ON ` + tb_windows + ` (pbc_id);`),
+	},
+	{
+		Version:     17,
+		Description: "add video durations",
+		Up: execMigration(`
+		ALTER TABLE ` + tb_playlist_items + ` ADD COLUMN duration_seconds INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE ` + tb_trash + ` ADD COLUMN duration_seconds INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE ` + tb_video_metadata + ` ADD COLUMN duration_seconds INTEGER NOT NULL DEFAULT 0;`),
+	},
+	{
+		Version:     18,
+		Description: "add fallback source to pbc_settings",
+		Up: execMigration(`
+		ALTER TABLE ` + tb_pbc_settings + ` ADD COLUMN fallback_source TEXT NOT NULL DEFAULT 'none';
+		ALTER TABLE ` + tb_pbc_settings + ` ADD COLUMN fallback_playlist_id VARCHAR(12) NOT NULL DEFAULT '';`),
+	},
+	{
+		Version:     19,
+		Description: "add clip end and loop to history",
+		Up: execMigration(`
+		ALTER TABLE ` + tb_history + ` ADD COLUMN end_seconds INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE ` + tb_history + ` ADD COLUMN loop BOOLEAN NOT NULL DEFAULT 0;`),
+	},
+	{
+		Version:     20,
+		Description: "create trash_votes table",
+		Up: execMigration(`
+		CREATE TABLE IF NOT EXISTS ` + tb_trash_votes + ` (
+			batch INTEGER NOT NULL,
+			item_id INTEGER NOT NULL,
+			voter VARCHAR(64) NOT NULL,
+			vote INTEGER NOT NULL,
+			voted_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (batch, item_id, voter)
+		);`),
+	},
+	{
+		Version:     21,
+		Description: "add the client address to playlist items and trash",
+		Up: execMigration(`
+		ALTER TABLE ` + tb_playlist_items + ` ADD COLUMN added_from VARCHAR(64) NOT NULL DEFAULT '';
+		ALTER TABLE ` + tb_trash + ` ADD COLUMN added_from VARCHAR(64) NOT NULL DEFAULT '';`),
+	},
+}
+
+// execMigration returns a migration Up func which runs the provided sql.
+func execMigration(query string) func(ctx context.Context, tx *sql.Tx) error {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, query)
+		return err
+	}
+}
+
+// convertJSONPlaylists copies each playlist json blob into 'playlist_items' and then empties the
+// blob.
+func convertJSONPlaylists(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, playlist FROM `+tb_playlists+` WHERE playlist != '[]'`)
+	if err != nil {
+		return fmt.Errorf("convertJSONPlaylists: %w", err)
+	}
+
+	blobs := make(map[string]string)
+	for rows.Next() {
+		var id, plData string
+		if err := rows.Scan(&id, &plData); err != nil {
+			rows.Close()
+			return fmt.Errorf("convertJSONPlaylists: %w", err)
+		}
+
+		blobs[id] = plData
+	}
+	rows.Close()
+
+	now := time.Now()
+	for id, plData := range blobs {
+		pl, err := UnmarshalPlaylists([]byte(plData))
+		if err != nil {
+			return fmt.Errorf("convertJSONPlaylists: %s: %w", id, err)
+		}
+
+		query := `INSERT INTO ` + tb_playlist_items + ` (pbc_id, position, added_at, video_id,
+			title, author_name, thumbnail_url, start_seconds) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+		for i, d := range pl {
+			_, err := tx.ExecContext(ctx, query, id, i+1, now, d.VideoID, d.Title, d.AuthorName,
+				d.ThumbnailURL, d.StartSeconds)
+			if err != nil {
+				return fmt.Errorf("convertJSONPlaylists: %s: %w", id, err)
+			}
+		}
+
+		query = `UPDATE ` + tb_playlists + ` SET playlist = '[]' WHERE id = ?`
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return fmt.Errorf("convertJSONPlaylists: %s: %w", id, err)
+		}
+	}
+
+	return nil
+}
+
+// latestMigration returns the version of the newest migration this binary knows about.
+func latestMigration() int {
+	if len(Migrations) == 0 {
+		return 0
+	}
+
+	return Migrations[len(Migrations)-1].Version
+}
+
+// migrationsInit creates the 'schema_migrations' table if it does not exist.
+func (db *SqliteDB) migrationsInit() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS ` + tb_schema_migrations + ` (
+		version INTEGER NOT NULL PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL
+	);`
+
+	if _, err := db.Exec(query); err != nil {
+		return fmt.Errorf("SqliteDB.migrationsInit: %w", err)
+	}
+
+	return nil
+}
+
+// appliedMigrations returns when each migration applied to the database was applied. It only
+// reads from the database, so a database without a 'schema_migrations' table has none applied.
+func (db *SqliteDB) appliedMigrations() (map[int]time.Time, error) {
+	row, err := db.QueryRow(
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`,
+		tb_schema_migrations,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("SqliteDB.appliedMigrations: %w", err)
+	}
+
+	var n int
+	if err := row.Scan(&n); err != nil {
+		return nil, fmt.Errorf("SqliteDB.appliedMigrations: %w", err)
+	}
+
+	applied := make(map[int]time.Time)
+	if n == 0 {
+		return applied, nil
+	}
+
+	rows, err := db.Query(`SELECT version, applied_at FROM ` + tb_schema_migrations)
+	if err != nil {
+		return nil, fmt.Errorf("SqliteDB.appliedMigrations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, fmt.Errorf("SqliteDB.appliedMigrations: %w", err)
+		}
+
+		applied[version] = at
+	}
+
+	return applied, rows.Err()
+}
+
+// SchemaVersion returns the newest migration version applied to the database.
+func (db *SqliteDB) SchemaVersion() (int, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return 0, fmt.Errorf("SqliteDB.SchemaVersion: %w", err)
+	}
+
+	return schemaVersion(applied), nil
+}
+
+// schemaVersion returns the newest version in applied.
+func schemaVersion(applied map[int]time.Time) int {
+	version := 0
+	for v := range applied {
+		version = max(version, v)
+	}
+
+	return version
+}
+
+// checkSchemaVersion returns ErrSchemaTooNew if version is newer than any migration this binary
+// knows about.
+func checkSchemaVersion(version int) error {
+	if version > latestMigration() {
+		return fmt.Errorf("%w: database version %d, latest known version %d",
+			ErrSchemaTooNew, version, latestMigration())
+	}
+
+	return nil
+}
+
+// MigrationStatus reports every known migration and whether it has been applied to the database.
+// The database is only read, so every migration is pending if it has never been migrated.
+func (db *SqliteDB) MigrationStatus() ([]MigrationStatus, error) {
+	applied, err := db.appliedMigrations()
+	if err != nil {
+		return nil, fmt.Errorf("SqliteDB.MigrationStatus: %w", err)
+	}
+
+	if err := checkSchemaVersion(schemaVersion(applied)); err != nil {
+		return nil, fmt.Errorf("SqliteDB.MigrationStatus: %w", err)
+	}
+
+	status := make([]MigrationStatus, 0, len(Migrations))
+	for _, m := range Migrations {
+		at, ok := applied[m.Version]
+		status = append(status, MigrationStatus{
+			Version:     m.Version,
+			Description: m.Description,
+			Applied:     ok,
+			AppliedAt:   at,
+		})
+	}
+
+	return status, nil
+}
+
+// Migrate applies every pending migration in order. Migrate refuses to run against a database
+// with a schema newer than this binary.
+func (db *SqliteDB) Migrate() error {
+	if err := db.migrationsInit(); err != nil {
+		return fmt.Errorf("SqliteDB.Migrate: %w", err)
+	}
+
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return fmt.Errorf("SqliteDB.Migrate: %w", err)
+	}
+
+	if err := checkSchemaVersion(version); err != nil {
+		return fmt.Errorf("SqliteDB.Migrate: %w", err)
+	}
+
+	for _, m := range Migrations {
+		if m.Version <= version {
+			continue
+		}
+
+		if err := db.applyMigration(m); err != nil {
+			return fmt.Errorf("SqliteDB.Migrate: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// MigrateDryRun applies every pending migration and then rolls it back, leaving the database
+// unchanged. The returned status holds the result of each pending migration. The dry run stops
+// at the first migration that fails since later migrations depend on it.
+func (db *SqliteDB) MigrateDryRun() ([]MigrationStatus, error) {
+	status, err := db.MigrationStatus()
+	if err != nil {
+		return nil, fmt.Errorf("SqliteDB.MigrateDryRun: %w", err)
+	}
+
+	tx, err := db.BeginTx(db.ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("SqliteDB.MigrateDryRun: %w", err)
+	}
+	defer tx.Rollback()
+
+	pending := make([]MigrationStatus, 0)
+	for i, m := range Migrations {
+		if status[i].Applied {
+			continue
+		}
+
+		s := status[i]
+		s.Err = m.Up(db.ctx, tx)
+		pending = append(pending, s)
+		if s.Err != nil {
+			break
+		}
+	}
+
+	return pending, nil
+}
+
+// applyMigration runs a single migration and records it in 'schema_migrations' inside one
+// transaction.
+func (db *SqliteDB) applyMigration(m Migration) error {
+	tx, err := db.BeginTx(db.ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migration %d: %w", m.Version, err)
+	}
+	defer tx.Rollback()
+
+	if err := m.Up(db.ctx, tx); err != nil {
+		return fmt.Errorf("migration %d (%s): %w", m.Version, m.Description, err)
+	}
+
+	query := `INSERT INTO ` + tb_schema_migrations + ` (version, description, applied_at) VALUES (?, ?, ?)`
+	if _, err := tx.ExecContext(db.ctx, query, m.Version, m.Description, time.Now()); err != nil {
+		return fmt.Errorf("migration %d: %w", m.Version, err)
+	}
+
+	return tx.Commit()
+}
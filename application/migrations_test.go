@@ -0,0 +1,164 @@
+package application
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// tableNames returns the names of every table in the database.
+func tableNames(t *testing.T, db *SqliteDB) []string {
+	t.Helper()
+
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' ORDER BY name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// useMigrations replaces Migrations until the test ends.
+func useMigrations(t *testing.T, list []Migration) {
+	t.Helper()
+
+	orig := Migrations
+	Migrations = list
+	t.Cleanup(func() { Migrations = orig })
+}
+
+func TestMigrateAppliesInOrder(t *testing.T) {
+	var order []int
+	step := func(v int) Migration {
+		return Migration{
+			Version:     v,
+			Description: "step",
+			Up: func(ctx context.Context, tx *sql.Tx) error {
+				order = append(order, v)
+				return nil
+			},
+		}
+	}
+
+	db := openTestDB(t)
+	useMigrations(t, []Migration{step(1), step(2), step(3)})
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	if !slices.Equal(order, []int{1, 2, 3}) {
+		t.Fatalf("applied %v, want [1 2 3]", order)
+	}
+
+	// Only migrations newer than the schema run on the next start.
+	order = nil
+	Migrations = append(Migrations, step(4))
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	if !slices.Equal(order, []int{4}) {
+		t.Fatalf("applied %v, want [4]", order)
+	}
+
+	if v, err := db.SchemaVersion(); err != nil || v != 4 {
+		t.Fatalf("got schema version %d, %v, want 4", v, err)
+	}
+}
+
+func TestMigrateStopsAtFailure(t *testing.T) {
+	errFailed := errors.New("failed")
+	db := openTestDB(t)
+	useMigrations(t, []Migration{
+		{Version: 1, Description: "ok", Up: execMigration(`CREATE TABLE one (id INTEGER);`)},
+		{Version: 2, Description: "fails", Up: func(ctx context.Context, tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, `CREATE TABLE two (id INTEGER);`); err != nil {
+				return err
+			}
+
+			return errFailed
+		}},
+	})
+
+	if err := db.Migrate(); !errors.Is(err, errFailed) {
+		t.Fatalf("got %v, want %v", err, errFailed)
+	}
+
+	// The failed migration is rolled back and not recorded.
+	if v, err := db.SchemaVersion(); err != nil || v != 1 {
+		t.Fatalf("got schema version %d, %v, want 1", v, err)
+	}
+
+	if names := tableNames(t, db); slices.Contains(names, "two") {
+		t.Fatalf("tables %v include one created by the failed migration", names)
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	db := newTestDB(t)
+
+	query := `INSERT INTO ` + tb_schema_migrations + ` (version, description, applied_at)
+		VALUES (?, ?, ?)`
+	if _, err := db.Exec(query, latestMigration()+1, "from the future", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Migrate(); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("Migrate: got %v, want %v", err, ErrSchemaTooNew)
+	}
+
+	if _, err := db.MigrationStatus(); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("MigrationStatus: got %v, want %v", err, ErrSchemaTooNew)
+	}
+
+	if _, err := db.MigrateDryRun(); !errors.Is(err, ErrSchemaTooNew) {
+		t.Fatalf("MigrateDryRun: got %v, want %v", err, ErrSchemaTooNew)
+	}
+}
+
+func TestMigrateDryRunLeavesDatabaseUnchanged(t *testing.T) {
+	db := openTestDB(t)
+
+	status, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatalf("MigrationStatus: %v", err)
+	}
+
+	applied := func(s MigrationStatus) bool { return s.Applied }
+	if len(status) != len(Migrations) || slices.ContainsFunc(status, applied) {
+		t.Fatalf("got %+v, want every migration pending", status)
+	}
+
+	pending, err := db.MigrateDryRun()
+	if err != nil {
+		t.Fatalf("MigrateDryRun: %v", err)
+	}
+
+	if len(pending) != len(Migrations) {
+		t.Fatalf("dry run covered %d migrations, want %d", len(pending), len(Migrations))
+	}
+
+	for _, s := range pending {
+		if s.Err != nil {
+			t.Fatalf("migration %d failed: %v", s.Version, s.Err)
+		}
+	}
+
+	if names := tableNames(t, db); len(names) != 0 {
+		t.Fatalf("dry run left tables %v behind", names)
+	}
+}
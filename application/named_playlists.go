@@ -0,0 +1,249 @@
+package application
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const maxPlaylistNameLength = 64
+
+// DuplicatePolicy decides what happens when a video which is already queued is added again.
+type DuplicatePolicy string
+
+const (
+	// DuplicatesReject refuses to queue the video a second time.
+	DuplicatesReject DuplicatePolicy = "reject"
+	// DuplicatesAllow queues the video again as a new item.
+	DuplicatesAllow DuplicatePolicy = "allow"
+	// DuplicatesMoveToEnd moves the existing item to the end of the playlist, or to the front
+	// when the video is added to play next.
+	DuplicatesMoveToEnd DuplicatePolicy = "move-to-end"
+)
+
+var (
+	ErrUnknownPlaylist = fmt.Errorf("playlist not found")
+	ErrPlaylistInUse   = fmt.Errorf("playlist is in use by a playback client")
+)
+
+// ParseDuplicatePolicy validates a duplicate policy name.
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	switch p := DuplicatePolicy(s); p {
+	case DuplicatesReject, DuplicatesAllow, DuplicatesMoveToEnd:
+		return p, nil
+	}
+
+	return "", fmt.Errorf("invalid duplicate policy: %q", s)
+}
+
+// PlaylistInfo describes a named playlist. A playlist is not tied to a playback client and may be
+// the active playlist of any number of them. Length is the number of videos in the playlist and is
+// filled in by the store.
+type PlaylistInfo struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	CreatedAt  time.Time       `json:"created_at"`
+	Duplicates DuplicatePolicy `json:"duplicates"`
+	Length     int             `json:"length"`
+}
+
+// NewPlaylistInfo creates a new PlaylistInfo with the given name and a random ID. The name must be
+// between 1 and 64 characters long.
+func NewPlaylistInfo(name string) (PlaylistInfo, error) {
+	name, err := validatePlaylistName(name)
+	if err != nil {
+		return PlaylistInfo{}, err
+	}
+
+	return PlaylistInfo{
+		ID:         NewPlaylistID(),
+		Name:       name,
+		CreatedAt:  time.Now(),
+		Duplicates: DuplicatesReject,
+	}, nil
+}
+
+// NewPlaylistID generates a random playlist ID with the same format as a playback client ID.
+func NewPlaylistID() string {
+	id := make([]rune, MAX_ID_LENGTH)
+	for i := range id {
+		id[i] = idRunes[rand.IntN(len(idRunes))]
+	}
+
+	return string(id)
+}
+
+func validatePlaylistName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return name, fmt.Errorf("playlist name is empty")
+	}
+
+	if utf8.RuneCountInString(name) > maxPlaylistNameLength {
+		return name, fmt.Errorf("playlist name is longer than %d characters", maxPlaylistNameLength)
+	}
+
+	return name, nil
+}
+
+// ListPlaylists returns every playlist in the store, oldest first.
+func (pls *Playlists) ListPlaylists() []PlaylistInfo {
+	pls.mu.RLock()
+	defer pls.mu.RUnlock()
+
+	infos := make([]PlaylistInfo, 0, len(pls.infos))
+	for id, info := range pls.infos {
+		info.Length = len(pls.lists[id])
+		infos = append(infos, info)
+	}
+
+	slices.SortFunc(infos, func(a, b PlaylistInfo) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a.ID, b.ID)
+	})
+
+	return infos
+}
+
+// GetPlaylist returns the playlist and a copy of its videos. The bool is false if the playlist does
+// not exist.
+func (pls *Playlists) GetPlaylist(id string) (PlaylistInfo, Playlist, bool) {
+	pls.mu.RLock()
+	defer pls.mu.RUnlock()
+
+	info, ok := pls.infos[id]
+	if !ok {
+		return info, nil, false
+	}
+
+	info.Length = len(pls.lists[id])
+	return info, slices.Clone(pls.lists[id]), true
+}
+
+// CreatePlaylist creates a new, empty playlist.
+func (pls *Playlists) CreatePlaylist(name string) (PlaylistInfo, error) {
+	info, err := NewPlaylistInfo(name)
+	if err != nil {
+		return info, err
+	}
+
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	if err := pls.db.PlaylistCreate(info); err != nil {
+		return info, fmt.Errorf("Playlists.CreatePlaylist: %w", err)
+	}
+
+	pls.infos[info.ID] = info
+	pls.lists[info.ID] = NewPlaylist()
+	return info, nil
+}
+
+// RenamePlaylist changes the name of the playlist.
+func (pls *Playlists) RenamePlaylist(id string, name string) (PlaylistInfo, error) {
+	name, err := validatePlaylistName(name)
+	if err != nil {
+		return PlaylistInfo{}, err
+	}
+
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	info, ok := pls.infos[id]
+	if !ok {
+		return info, ErrUnknownPlaylist
+	}
+
+	if err := pls.db.PlaylistRename(id, name); err != nil {
+		return info, fmt.Errorf("Playlists.RenamePlaylist: %w", err)
+	}
+
+	info.Name = name
+	pls.infos[id] = info
+
+	info.Length = len(pls.lists[id])
+	return info, nil
+}
+
+// SetDuplicatePolicy changes how the playlist handles videos which are already queued.
+func (pls *Playlists) SetDuplicatePolicy(id string, policy DuplicatePolicy) (PlaylistInfo, error) {
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	info, ok := pls.infos[id]
+	if !ok {
+		return info, ErrUnknownPlaylist
+	}
+
+	if err := pls.db.PlaylistSetDuplicates(id, policy); err != nil {
+		return info, fmt.Errorf("Playlists.SetDuplicatePolicy: %w", err)
+	}
+
+	info.Duplicates = policy
+	pls.infos[id] = info
+
+	info.Length = len(pls.lists[id])
+	return info, nil
+}
+
+// DeletePlaylist deletes the playlist and its videos. A playlist which is the active or fallback
+// playlist of any playback client cannot be deleted.
+func (pls *Playlists) DeletePlaylist(id string) error {
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	if _, ok := pls.infos[id]; !ok {
+		return ErrUnknownPlaylist
+	}
+
+	for _, pbc := range pls.clients {
+		if pbc.PlaylistID == id {
+			return fmt.Errorf("%w: %s", ErrPlaylistInUse, pbc.Name)
+		}
+
+		st := pls.settingsFor(pbc.ID)
+		if st.FallbackSource == FallbackPlaylist && st.FallbackPlaylistID == id {
+			return fmt.Errorf("%w: %s uses it as its fallback", ErrPlaylistInUse, pbc.Name)
+		}
+	}
+
+	if err := pls.db.PlaylistDelete(id); err != nil {
+		return fmt.Errorf("Playlists.DeletePlaylist: %w", err)
+	}
+
+	delete(pls.infos, id)
+	delete(pls.lists, id)
+	return nil
+}
+
+// SetActivePlaylist switches the playback client to play from the playlist. The video the playback
+// client was playing is left where it is in the old playlist.
+func (pls *Playlists) SetActivePlaylist(pbc PlaybackClient, id string) (PlaybackClient, error) {
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	if _, ok := pls.infos[id]; !ok {
+		return pbc, ErrUnknownPlaylist
+	}
+
+	if err := pls.db.PlaybackClientSetPlaylist(pbc.ID, id); err != nil {
+		return pbc, fmt.Errorf("Playlists.SetActivePlaylist: %w", err)
+	}
+
+	if c, ok := pls.clients[pbc.ID]; ok {
+		pbc = c
+	}
+
+	pbc.PlaylistID = id
+	pls.clients[pbc.ID] = pbc
+	delete(pls.playing, pbc.ID)
+	pls.events.Publish(Event{Type: EventPlaylistUpdated, PBCID: pbc.ID})
+
+	return pbc, nil
+}
@@ -26,10 +26,12 @@ var (
 	regPBCName = regexp.MustCompile(`^[a-zA-Z0-9 _-]{2,32}$`)
 )
 
-// PlaybackClient holds the ID and Name of a playback client.
+// PlaybackClient holds the ID and Name of a playback client. PlaylistID is the playlist the
+// playback client plays from and is empty if it has no active playlist.
 type PlaybackClient struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	PlaylistID string `json:"playlist_id"`
 }
 
 // NewPlaybackClient creates a new PlaybackClient with the given name and generates an ID.
@@ -0,0 +1,229 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultYouTubeAPIURL  = "https://www.googleapis.com/youtube/v3"
+	DefaultYouTubeFeedURL = "https://www.youtube.com/feeds/videos.xml"
+)
+
+var (
+	listFormat = regexp.MustCompile(`^[a-zA-Z0-9_-]{12,64}$`)
+
+	ErrPlaylistNotFound = fmt.Errorf("youtube playlist not found")
+)
+
+// ParsePlaylistURL returns the playlist ID from a YouTube URL's 'list' parameter. A bare playlist ID
+// is also accepted.
+func ParsePlaylistURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("ParsePlaylistURL: url - %w", ErrParamEmpty)
+	}
+
+	if listFormat.MatchString(raw) {
+		return raw, nil
+	}
+
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("ParsePlaylistURL: %w", err)
+	}
+
+	if _, ok := youtubeHost(u); !ok {
+		return "", fmt.Errorf("ParsePlaylistURL: %w: unknown host %s", ErrInvalidURL, u.Host)
+	}
+
+	list := u.Query().Get("list")
+	if !listFormat.MatchString(list) {
+		return "", fmt.Errorf("ParsePlaylistURL: invalid playlist id: %q", list)
+	}
+
+	return list, nil
+}
+
+// PlaylistFetcher expands a YouTube playlist into its video IDs in playlist order.
+type PlaylistFetcher interface {
+	PlaylistVideos(ctx context.Context, listID string) ([]string, error)
+}
+
+// YouTubeAPIFetcher gets playlist videos from the YouTube Data API. It requires an API key.
+type YouTubeAPIFetcher struct {
+	APIKey  string
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewYouTubeAPIFetcher creates a new YouTubeAPIFetcher. If baseURL is empty the YouTube Data API
+// is used.
+func NewYouTubeAPIFetcher(apiKey, baseURL string) *YouTubeAPIFetcher {
+	if baseURL == "" {
+		baseURL = DefaultYouTubeAPIURL
+	}
+
+	return &YouTubeAPIFetcher{
+		APIKey:  apiKey,
+		BaseURL: baseURL,
+		Client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// PlaylistVideos pages through the playlist's items and returns their video IDs. Private and
+// deleted videos are left out since they can't be played. YouTube doesn't give them a published
+// date.
+func (f *YouTubeAPIFetcher) PlaylistVideos(ctx context.Context, listID string) ([]string, error) {
+	var vids []string
+	page := ""
+	for len(vids) < maxBatchVideos {
+		q := url.Values{}
+		q.Set("part", "contentDetails")
+		q.Set("maxResults", "50")
+		q.Set("playlistId", listID)
+		q.Set("key", f.APIKey)
+		if page != "" {
+			q.Set("pageToken", page)
+		}
+
+		body, err := fetch(ctx, f.Client, f.BaseURL+"/playlistItems?"+q.Encode())
+		if err != nil {
+			return vids, fmt.Errorf("YouTubeAPIFetcher.PlaylistVideos: %w", err)
+		}
+
+		var res struct {
+			NextPageToken string `json:"nextPageToken"`
+			Items         []struct {
+				ContentDetails struct {
+					VideoID          string `json:"videoId"`
+					VideoPublishedAt string `json:"videoPublishedAt"`
+				} `json:"contentDetails"`
+			} `json:"items"`
+		}
+
+		if err := json.Unmarshal(body, &res); err != nil {
+			return vids, fmt.Errorf("YouTubeAPIFetcher.PlaylistVideos: error unmarshalling response body: %w", err)
+		}
+
+		for _, item := range res.Items {
+			if item.ContentDetails.VideoID == "" || item.ContentDetails.VideoPublishedAt == "" {
+				continue
+			}
+
+			vids = append(vids, item.ContentDetails.VideoID)
+		}
+
+		if res.NextPageToken == "" {
+			break
+		}
+
+		page = res.NextPageToken
+	}
+
+	return vids, nil
+}
+
+// YouTubeFeedFetcher gets playlist videos from the public YouTube playlist feed. It does not need
+// an API key but YouTube only includes the first 15 videos in the feed.
+type YouTubeFeedFetcher struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewYouTubeFeedFetcher creates a new YouTubeFeedFetcher. If baseURL is empty the YouTube feed is
+// used.
+func NewYouTubeFeedFetcher(baseURL string) *YouTubeFeedFetcher {
+	if baseURL == "" {
+		baseURL = DefaultYouTubeFeedURL
+	}
+
+	return &YouTubeFeedFetcher{
+		BaseURL: baseURL,
+		Client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// PlaylistVideos reads the video IDs from the playlist's feed.
+func (f *YouTubeFeedFetcher) PlaylistVideos(ctx context.Context, listID string) ([]string, error) {
+	body, err := fetch(ctx, f.Client, f.BaseURL+"?playlist_id="+url.QueryEscape(listID))
+	if err != nil {
+		return nil, fmt.Errorf("YouTubeFeedFetcher.PlaylistVideos: %w", err)
+	}
+
+	var feed struct {
+		Entries []struct {
+			VideoID string `xml:"videoId"`
+		} `xml:"entry"`
+	}
+
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, fmt.Errorf("YouTubeFeedFetcher.PlaylistVideos: error unmarshalling response body: %w", err)
+	}
+
+	vids := make([]string, 0, len(feed.Entries))
+	for _, e := range feed.Entries {
+		vids = append(vids, e.VideoID)
+	}
+
+	return vids, nil
+}
+
+// fetch makes a GET request and returns the response body. Not found responses return
+// ErrPlaylistNotFound.
+func fetch(ctx context.Context, client *http.Client, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "yt-queuer")
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusBadRequest:
+		return nil, ErrPlaylistNotFound
+	default:
+		return nil, fmt.Errorf("unexpected response: %d", res.StatusCode)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+
+	return body, nil
+}
+
+// ImportVideos appends the videos to the end of the playback client's playlist in one batch.
+// Videos rejected by the playlist's duplicate policy are skipped. A result is returned for every
+// video in the order given.
+func (pls *Playlists) ImportVideos(pbc PlaybackClient, vids []string, by Submitter) ([]AddResult, error) {
+	if len(vids) > maxBatchVideos {
+		vids = vids[:maxBatchVideos]
+	}
+
+	items := make([]BulkItem, len(vids))
+	for i, vid := range vids {
+		items[i] = BulkItem{Video: vid}
+	}
+
+	return pls.AddBatch(pbc, items, -1, by)
+}
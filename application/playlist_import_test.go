@@ -0,0 +1,261 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// fakePlaylistFetcher is a PlaylistFetcher which answers from memory. Playlists not in the map
+// return ErrPlaylistNotFound.
+type fakePlaylistFetcher struct {
+	lists map[string][]string
+}
+
+func (f *fakePlaylistFetcher) PlaylistVideos(ctx context.Context, listID string) ([]string, error) {
+	vids, ok := f.lists[listID]
+	if !ok {
+		return nil, ErrPlaylistNotFound
+	}
+
+	return vids, nil
+}
+
+type apiItem struct {
+	VideoID     string
+	PublishedAt string
+}
+
+// newAPIStub starts a local stand in for the YouTube Data API playlistItems endpoint which serves
+// pages in order. Each page's nextPageToken is the index of the next page. If endless is set it
+// keeps serving the last page. It returns the server and a func which reports how many requests
+// were made.
+func newAPIStub(t *testing.T, pages [][]apiItem, endless bool) (*httptest.Server, func() int) {
+	t.Helper()
+
+	var mu sync.Mutex
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+
+		q := r.URL.Query()
+		if r.URL.Path != "/playlistItems" || q.Get("key") != "key" {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+
+		if q.Get("playlistId") != "PLtestplaylist" {
+			http.Error(w, "", http.StatusNotFound)
+			return
+		}
+
+		i := 0
+		if tok := q.Get("pageToken"); tok != "" {
+			i, _ = strconv.Atoi(tok)
+		}
+
+		type details struct {
+			VideoID          string `json:"videoId,omitempty"`
+			VideoPublishedAt string `json:"videoPublishedAt,omitempty"`
+		}
+
+		var res struct {
+			NextPageToken string `json:"nextPageToken,omitempty"`
+			Items         []struct {
+				ContentDetails details `json:"contentDetails"`
+			} `json:"items"`
+		}
+
+		for _, item := range pages[min(i, len(pages)-1)] {
+			res.Items = append(res.Items, struct {
+				ContentDetails details `json:"contentDetails"`
+			}{details{item.VideoID, item.PublishedAt}})
+		}
+
+		if i+1 < len(pages) || endless {
+			res.NextPageToken = strconv.Itoa(i + 1)
+		}
+
+		json.NewEncoder(w).Encode(res)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return requests
+	}
+}
+
+func published(vids ...string) []apiItem {
+	items := make([]apiItem, len(vids))
+	for i, vid := range vids {
+		items[i] = apiItem{VideoID: vid, PublishedAt: "2024-01-01T00:00:00Z"}
+	}
+
+	return items
+}
+
+func TestYouTubeAPIFetcherPagination(t *testing.T) {
+	srv, requests := newAPIStub(t, [][]apiItem{
+		published("aaaaaaaaaaa", "bbbbbbbbbbb"),
+		published("ccccccccccc"),
+		published("ddddddddddd", "eeeeeeeeeee"),
+	}, false)
+
+	f := NewYouTubeAPIFetcher("key", srv.URL)
+	vids, err := f.PlaylistVideos(context.Background(), "PLtestplaylist")
+	if err != nil {
+		t.Fatalf("PlaylistVideos: %v", err)
+	}
+
+	want := []string{"aaaaaaaaaaa", "bbbbbbbbbbb", "ccccccccccc", "ddddddddddd", "eeeeeeeeeee"}
+	if !slices.Equal(vids, want) {
+		t.Fatalf("got %v, want %v", vids, want)
+	}
+
+	if requests() != 3 {
+		t.Fatalf("got %d requests, want 3", requests())
+	}
+}
+
+func TestYouTubeAPIFetcherSkipsPrivateAndDeleted(t *testing.T) {
+	srv, _ := newAPIStub(t, [][]apiItem{{
+		{VideoID: "aaaaaaaaaaa", PublishedAt: "2024-01-01T00:00:00Z"},
+		{VideoID: "bbbbbbbbbbb"}, // private
+		{},                       // deleted
+		{VideoID: "ccccccccccc", PublishedAt: "2024-01-02T00:00:00Z"},
+	}}, false)
+
+	f := NewYouTubeAPIFetcher("key", srv.URL)
+	vids, err := f.PlaylistVideos(context.Background(), "PLtestplaylist")
+	if err != nil {
+		t.Fatalf("PlaylistVideos: %v", err)
+	}
+
+	if want := []string{"aaaaaaaaaaa", "ccccccccccc"}; !slices.Equal(vids, want) {
+		t.Fatalf("got %v, want %v", vids, want)
+	}
+}
+
+func TestYouTubeAPIFetcherNotFound(t *testing.T) {
+	srv, _ := newAPIStub(t, [][]apiItem{published("aaaaaaaaaaa")}, false)
+
+	f := NewYouTubeAPIFetcher("key", srv.URL)
+	if _, err := f.PlaylistVideos(context.Background(), "PLmissinglist"); !errors.Is(err, ErrPlaylistNotFound) {
+		t.Fatalf("got %v, want %v", err, ErrPlaylistNotFound)
+	}
+}
+
+func TestYouTubeAPIFetcherStopsAtBatchLimit(t *testing.T) {
+	page := make([]string, 50)
+	for i := range page {
+		page[i] = testVideoID(i)
+	}
+
+	srv, requests := newAPIStub(t, [][]apiItem{published(page...)}, true)
+
+	f := NewYouTubeAPIFetcher("key", srv.URL)
+	vids, err := f.PlaylistVideos(context.Background(), "PLtestplaylist")
+	if err != nil {
+		t.Fatalf("PlaylistVideos: %v", err)
+	}
+
+	if len(vids) != maxBatchVideos {
+		t.Fatalf("got %d videos, want %d", len(vids), maxBatchVideos)
+	}
+
+	if want := maxBatchVideos / 50; requests() != want {
+		t.Fatalf("got %d requests, want %d", requests(), want)
+	}
+}
+
+func TestImportHandler(t *testing.T) {
+	pls, pbc := newTestPlaylists(t)
+	if _, err := pls.Add(pbc, VideoURL{VideoID: "bbbbbbbbbbb"}, Submitter{Name: "test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	fetcher := &fakePlaylistFetcher{lists: map[string][]string{
+		"PLtestplaylist": {"aaaaaaaaaaa", "bbbbbbbbbbb", "not a video", "ccccccccccc"},
+	}}
+
+	s := NewHTTPServer(pls.Logger, "", 0, "", "", pls, nil, nil, nil, fetcher, pls.db)
+	s.AddRoutes()
+
+	post := func(list string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		u := "/playlists/" + pbc.ID + "/import?url=" + url.QueryEscape("https://www.youtube.com/playlist?list="+list)
+		s.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, u, nil))
+		return w
+	}
+
+	w := post("PLtestplaylist")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+
+	var res struct {
+		Results []AddResult `json:"results"`
+	}
+
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, r := range res.Results {
+		got = append(got, r.Status)
+	}
+
+	want := []string{ResultAdded, ResultSkipped, ResultFailed, ResultAdded}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	pl, _ := pls.Get(pbc)
+	if len(pl) != 3 {
+		t.Fatalf("got %d videos queued, want 3", len(pl))
+	}
+
+	if w := post("PLmissinglist"); w.Code != http.StatusNotFound {
+		t.Fatalf("got status %d for a missing playlist, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestImportVideosCapsBatch(t *testing.T) {
+	pls, pbc := newTestPlaylists(t)
+
+	vids := make([]string, maxBatchVideos+100)
+	for i := range vids {
+		vids[i] = testVideoID(i)
+	}
+
+	results, err := pls.ImportVideos(pbc, vids, Submitter{Name: "test"})
+	if err != nil {
+		t.Fatalf("ImportVideos: %v", err)
+	}
+
+	if len(results) != maxBatchVideos {
+		t.Fatalf("got %d results, want %d", len(results), maxBatchVideos)
+	}
+
+	pl, _ := pls.Get(pbc)
+	if len(pl) != maxBatchVideos {
+		t.Fatalf("got %d videos queued, want %d", len(pl), maxBatchVideos)
+	}
+
+	if last := pl[len(pl)-1].VideoID; last != testVideoID(maxBatchVideos-1) {
+		t.Fatalf("last video is %s, want %s", last, testVideoID(maxBatchVideos-1))
+	}
+}
@@ -3,63 +3,71 @@ package application
 import (
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
+	"log"
+	"math/rand/v2"
 	"regexp"
+	"slices"
+	"sync"
 	"time"
 )
 
 var (
 	vidFormat = regexp.MustCompile(`^[a-zA-Z0-9_-]{10}[a-zA-Z0-9]$`)
-	ytURL     = "https://www.youtube.com/oembed?format=json&url=https://www.youtube.com/watch?v=%s"
 
 	ErrPlaylistEmpty = fmt.Errorf("queue is empty")
 	ErrEndOfPlaylist = fmt.Errorf("no more videos in queue")
+	ErrVideoQueued   = fmt.Errorf("video already in queue")
+	ErrItemNotFound  = fmt.Errorf("item not found in queue")
+
+	ErrNoActivePlaylist = fmt.Errorf("playback client has no active playlist")
 )
 
+// VideoDetails is a single entry in a playlist. ItemID is assigned when the video is queued and
+// identifies the entry, so the same video may be queued more than once. Upvotes and Downvotes are
+// the votes cast on the entry while it is queued. DurationSeconds is the length of the whole video,
+// or 0 if it is not known yet. AddedBy is the nickname shown for whoever added the video and
+// AddedFrom is the address of the client it was added from, which is not sent to clients. Fallback
+// is set on videos played from the fallback source while the queue is empty.
 type VideoDetails struct {
-	VideoID      string `json:"video_id"`
-	Title        string `json:"title"`
-	AuthorName   string `json:"author_name"`
-	ThumbnailURL string `json:"thumbnail_url"`
-	StartSeconds int    `json:"start_seconds"`
+	ItemID          int64     `json:"item_id,omitempty"`
+	VideoID         string    `json:"video_id"`
+	Title           string    `json:"title"`
+	AuthorName      string    `json:"author_name"`
+	ThumbnailURL    string    `json:"thumbnail_url"`
+	StartSeconds    int       `json:"start_seconds"`
+	EndSeconds      int       `json:"end_seconds"`
+	Loop            bool      `json:"loop"`
+	DurationSeconds int       `json:"duration_seconds"`
+	AddedAt         time.Time `json:"added_at"`
+	AddedBy         string    `json:"added_by"`
+	AddedFrom       string    `json:"-"`
+	Upvotes         int       `json:"upvotes"`
+	Downvotes       int       `json:"downvotes"`
+	Fallback        bool      `json:"fallback,omitempty"`
 }
 
-func NewDetails(vid string, start int) (VideoDetails, error) {
-	d := VideoDetails{VideoID: vid, StartSeconds: start}
-
-	client := http.Client{
-		Timeout: 2 * time.Second,
-	}
-
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(ytURL, vid), nil)
-	if err != nil {
-		return d, fmt.Errorf("NewDetails - error creating request: %v", err)
-	}
-
-	req.Header.Set("User-Agent", "yt-queuer")
-	res, err := client.Do(req)
-	if err != nil {
-		return d, fmt.Errorf("NewDetails - error making request: %v", err)
-	}
-
-	if res.StatusCode != http.StatusOK || res.Body == nil {
-		return d, fmt.Errorf("NewDetails - youtube returned unexpected response or empty body: %d", res.StatusCode)
+// submitter returns who the per-submitter queue limit counts the item against. This is the client
+// the item was added from, or the nickname for items saved without one.
+func (d VideoDetails) submitter() string {
+	if d.AddedFrom != "" {
+		return d.AddedFrom
 	}
 
-	defer res.Body.Close()
-
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return d, fmt.Errorf("NewDetails - error reading response body: %v", err)
-	}
+	return d.AddedBy
+}
 
-	err = json.Unmarshal(body, &d)
-	if err != nil {
-		return d, fmt.Errorf("NewDetails - error unmarshalling response body: %v", err)
-	}
+// Submitter is who is adding videos. Name is the nickname recorded as who added each video and
+// which fair mode takes turns between. Client is the address of the client adding them, which the
+// per-submitter queue limit counts against so a new nickname doesn't reset it. Without a Client the
+// Name is counted instead.
+type Submitter struct {
+	Name   string
+	Client string
+}
 
-	return d, nil
+// key returns what the submitter's videos are counted against.
+func (s Submitter) key() string {
+	return VideoDetails{AddedBy: s.Name, AddedFrom: s.Client}.submitter()
 }
 
 type Playlist []VideoDetails
@@ -68,28 +76,6 @@ func NewPlaylist() Playlist {
 	return make([]VideoDetails, 0)
 }
 
-func (pls Playlists) Save(db *SqliteDB, pbc PlaybackClient) error {
-	if pbc.ID == "" {
-		return fmt.Errorf("PlaybackClient.ID is empty")
-	}
-
-	if pbc.Name == "" {
-		return fmt.Errorf("PlaybackClient.Name is empty")
-	}
-
-	if err := db.PlaylistUpdate(pbc, pls[pbc]); err != nil {
-		return fmt.Errorf("Playlist.Save: %w", err)
-	}
-
-	return nil
-}
-
-type Playlists map[PlaybackClient]Playlist
-
-func NewPlaylists() Playlists {
-	return make(map[PlaybackClient]Playlist, 0)
-}
-
 func UnmarshalPlaylists(data []byte) (Playlist, error) {
 	var pl Playlist
 	err := json.Unmarshal(data, &pl)
@@ -113,126 +99,741 @@ func validateVideoID(vid string) error {
 }
 
 func (pl Playlist) isDuplicate(vid string) bool {
-	for _, d := range pl {
-		if d.VideoID == vid {
-			return true
+	return pl.indexOfVideo(vid) >= 0
+}
+
+// indexOf returns the index of the item or -1 if it is not in the playlist.
+func (pl Playlist) indexOf(itemID int64) int {
+	return slices.IndexFunc(pl, func(d VideoDetails) bool { return d.ItemID == itemID })
+}
+
+// indexOfVideo returns the index of the first item for the video or -1 if it is not queued.
+func (pl Playlist) indexOfVideo(vid string) int {
+	return slices.IndexFunc(pl, func(d VideoDetails) bool { return d.VideoID == vid })
+}
+
+// MoveTarget is where an item should be moved to in a playlist. Exactly one of Index, Before, or
+// After should be set. Before and After hold the ID of the item to move next to.
+type MoveTarget struct {
+	Index  *int
+	Before int64
+	After  int64
+}
+
+// Validate checks that exactly one target has been set.
+func (t MoveTarget) Validate() error {
+	n := 0
+	if t.Index != nil {
+		n++
+	}
+
+	if t.Before != 0 {
+		n++
+	}
+
+	if t.After != 0 {
+		n++
+	}
+
+	if n != 1 {
+		return fmt.Errorf("exactly one of index, before, or after is required")
+	}
+
+	return nil
+}
+
+// Move returns a copy of the playlist with the item moved to the target. An index past the end of
+// the playlist moves the item to the end.
+func (pl Playlist) Move(itemID int64, target MoveTarget) (Playlist, error) {
+	if err := target.Validate(); err != nil {
+		return pl, err
+	}
+
+	i := pl.indexOf(itemID)
+	if i < 0 {
+		return pl, fmt.Errorf("%w: %d", ErrItemNotFound, itemID)
+	}
+
+	d := pl[i]
+	n := slices.Delete(slices.Clone(pl), i, i+1)
+
+	var to int
+	switch {
+	case target.Index != nil:
+		to = *target.Index
+		if to < 0 {
+			return pl, fmt.Errorf("invalid index: %d", to)
 		}
+
+		to = min(to, len(n))
+	case target.Before != 0:
+		to = n.indexOf(target.Before)
+		if to < 0 {
+			return pl, fmt.Errorf("%w: %d", ErrItemNotFound, target.Before)
+		}
+	case target.After != 0:
+		to = n.indexOf(target.After)
+		if to < 0 {
+			return pl, fmt.Errorf("%w: %d", ErrItemNotFound, target.After)
+		}
+
+		to++
 	}
 
-	return false
+	return slices.Insert(n, to, d), nil
 }
 
-func (pls Playlists) LoadFromDB(db *SqliteDB) error {
-	all, err := db.PlaylistGetAll()
+// Playlists is the concurrency safe store for every playlist and playback client. Handlers run on
+// their own goroutines so all access to the underlying maps goes through the store's lock. Methods
+// which modify a playlist hold the lock for the whole read-modify-write and persist the change to
+// the database before it replaces the cached copy. If the database write fails the cached
+// playlist is left untouched.
+//
+// Playlists are stored by their own ID. Each playback client plays from its active playlist and
+// the methods which take a PlaybackClient work on that playlist. Several playback clients may
+// share the same active playlist.
+//
+// New videos are added with only their ID. Their metadata is filled in by the MetadataResolver and
+// every change is published to the Broker so watching clients can refresh.
+type Playlists struct {
+	Logger *log.Logger
+
+	db       *SqliteDB
+	metadata *MetadataResolver
+	events   *Broker
+
+	mu       sync.RWMutex
+	clients  map[string]PlaybackClient
+	infos    map[string]PlaylistInfo
+	lists    map[string]Playlist
+	playing  map[string]nowPlaying
+	settings map[string]PBCSettings
+	advances map[string][]advance
+	filters  map[string][]FilterRule
+	windows  map[string][]PlaybackWindow
+
+	fallbacks      map[string]fallbackPick
+	trashRetention time.Duration
+}
+
+// NewPlaylists creates a new, empty playlist store backed by db. metadata and events may be nil.
+func NewPlaylists(logger *log.Logger, db *SqliteDB, metadata *MetadataResolver, events *Broker) *Playlists {
+	return &Playlists{
+		Logger:   logger,
+		db:       db,
+		metadata: metadata,
+		events:   events,
+		clients:  make(map[string]PlaybackClient),
+		infos:    make(map[string]PlaylistInfo),
+		lists:    make(map[string]Playlist),
+		playing:  make(map[string]nowPlaying),
+		settings: make(map[string]PBCSettings),
+		advances: make(map[string][]advance),
+		filters:  make(map[string][]FilterRule),
+		windows:  make(map[string][]PlaybackWindow),
+
+		fallbacks:      make(map[string]fallbackPick),
+		trashRetention: DefaultTrashRetention,
+	}
+}
+
+// LoadFromDB loads every playback client and playlist from the database into the store.
+func (pls *Playlists) LoadFromDB() error {
+	pbcs, err := pls.db.PlaybackClientList()
 	if err != nil {
 		return fmt.Errorf("Playlists.LoadFromDB: %w", err)
 	}
 
-	if len(all) == 0 {
-		return nil
+	infos, err := pls.db.PlaylistList()
+	if err != nil {
+		return fmt.Errorf("Playlists.LoadFromDB: %w", err)
+	}
+
+	settings, err := pls.db.SettingsList()
+	if err != nil {
+		return fmt.Errorf("Playlists.LoadFromDB: %w", err)
+	}
+
+	rules, err := pls.db.FilterRuleList()
+	if err != nil {
+		return fmt.Errorf("Playlists.LoadFromDB: %w", err)
+	}
+
+	windows, err := pls.db.WindowList()
+	if err != nil {
+		return fmt.Errorf("Playlists.LoadFromDB: %w", err)
 	}
 
-	for pbc, pl := range all {
-		pls[pbc] = pl
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	for _, st := range settings {
+		pls.settings[st.PBCID] = st
+	}
+
+	for _, r := range rules {
+		pls.filters[r.PBCID] = append(pls.filters[r.PBCID], r)
+	}
+
+	for _, w := range windows {
+		if err := w.prepare(); err != nil {
+			return fmt.Errorf("Playlists.LoadFromDB: playback window %d: %w", w.ID, err)
+		}
+
+		pls.windows[w.PBCID] = append(pls.windows[w.PBCID], w)
+	}
+
+	for _, pbc := range pbcs {
+		pls.clients[pbc.ID] = pbc
+	}
+
+	for _, info := range infos {
+		pl, err := pls.db.PlaylistItemList(info.ID)
+		if err != nil {
+			return fmt.Errorf("Playlists.LoadFromDB: %w", err)
+		}
+
+		pls.infos[info.ID] = info
+		pls.lists[info.ID] = pl
+
+		// Pick up any videos that were still waiting on metadata when we last shut down.
+		for _, d := range pl {
+			if d.Title == "" {
+				pls.metadata.Enqueue(d.VideoID)
+			}
+		}
 	}
 
 	return nil
 }
 
-func (pls Playlists) GetPBCs() []PlaybackClient {
-	if len(pls) == 0 {
-		return []PlaybackClient{}
+// Register returns the playback client with the given name. If the playback client does not
+// exist it is created along with an empty playlist of the same name which becomes its active
+// playlist.
+func (pls *Playlists) Register(name string) (PlaybackClient, error) {
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	pbc, err := pls.db.PlaybackClientGetByName(name)
+	if err == nil {
+		if _, ok := pls.clients[pbc.ID]; !ok {
+			pls.clients[pbc.ID] = pbc
+		}
+
+		return pls.clients[pbc.ID], nil
+	}
+
+	pbc, err = NewPlaybackClient(name)
+	if err != nil {
+		return pbc, err
+	}
+
+	// Give the playback client's first playlist the same ID so the repeatable ID also recovers
+	// its playlist.
+	info := PlaylistInfo{
+		ID:         pbc.ID,
+		Name:       pbc.Name,
+		CreatedAt:  time.Now(),
+		Duplicates: DuplicatesReject,
+	}
+	if err := pls.db.PlaylistCreate(info); err != nil {
+		return pbc, fmt.Errorf("Playlists.Register: %w", err)
 	}
 
-	pbcs := make([]PlaybackClient, 0, len(pls))
-	for pbc := range pls {
+	pbc.PlaylistID = info.ID
+	if err := pls.db.PlaybackClientCreate(pbc); err != nil {
+		if err := pls.db.PlaylistDelete(info.ID); err != nil {
+			return pbc, fmt.Errorf("Playlists.Register: %w", err)
+		}
+
+		return pbc, fmt.Errorf("Playlists.Register: %w", err)
+	}
+
+	pls.clients[pbc.ID] = pbc
+	pls.infos[info.ID] = info
+	pls.lists[info.ID] = NewPlaylist()
+	return pbc, nil
+}
+
+// GetPBCs returns every playback client in the store.
+func (pls *Playlists) GetPBCs() []PlaybackClient {
+	pls.mu.RLock()
+	defer pls.mu.RUnlock()
+
+	pbcs := make([]PlaybackClient, 0, len(pls.clients))
+	for _, pbc := range pls.clients {
 		pbcs = append(pbcs, pbc)
 	}
 
 	return pbcs
 }
 
-func (pls Playlists) Add(pbc PlaybackClient, vid string, start int) error {
-	if err := validateVideoID(vid); err != nil {
-		return err
+// Get returns a copy of the playback client's active playlist. The bool is false if the playback
+// client has no active playlist.
+func (pls *Playlists) Get(pbc PlaybackClient) (Playlist, bool) {
+	pls.mu.RLock()
+	defer pls.mu.RUnlock()
+
+	id, pl := pls.active(pbc)
+	if id == "" {
+		return nil, false
 	}
 
-	if pls[pbc].isDuplicate(vid) {
-		return fmt.Errorf("video already in queue: %s", vid)
+	return slices.Clone(pl), true
+}
+
+// active returns the ID and videos of the playback client's active playlist. The ID is empty if the
+// playback client has no active playlist. The caller must hold the lock.
+func (pls *Playlists) active(pbc PlaybackClient) (string, Playlist) {
+	id := pls.clients[pbc.ID].PlaylistID
+	if _, ok := pls.infos[id]; !ok {
+		return "", nil
 	}
 
-	d, err := NewDetails(vid, start)
-	if err != nil {
-		return err
+	return id, pls.lists[id]
+}
+
+// set replaces the cached playlist and notifies watchers of every playback client playing from it.
+// The change must already be saved to the database and the caller must hold the write lock.
+func (pls *Playlists) set(id string, pl Playlist) {
+	pls.lists[id] = pl
+	for _, pbc := range pls.clients {
+		if pbc.PlaylistID == id {
+			pls.events.Publish(Event{Type: EventPlaylistUpdated, PBCID: pbc.ID})
+		}
 	}
+}
 
-	pls[pbc] = append(pls[pbc], d)
+// Add appends the video to the end of the playback client's playlist and returns the new item. by
+// identifies who added the video. In fair mode the video goes after the next video of everyone
+// else with videos queued instead, and in vote-ordered mode after every video without downvotes.
+// If the video's metadata is not cached the video is added right away with just its ID and the
+// metadata is looked up in the background.
+//
+// If the video is already queued the playlist's duplicate policy decides what happens. The video
+// is either rejected with ErrVideoQueued, queued again, or the existing item is moved to the end
+// and returned. A video which would take the queue over one of the playback client's limits is
+// rejected with an error wrapping ErrQueueFull, ErrSubmitterLimit, or ErrQueueDuration, and one
+// which the playback client's filter rules block with an error wrapping ErrVideoBlocked.
+func (pls *Playlists) Add(pbc PlaybackClient, v VideoURL, by Submitter) (VideoDetails, error) {
+	return pls.insert(pbc, v, by, false)
+}
 
-	return nil
+// PlayNext adds the video to the front of the playback client's playlist and returns the new item.
+// by identifies who added the video. Duplicates are handled as they are by Add except that an
+// existing item is moved to the front.
+func (pls *Playlists) PlayNext(pbc PlaybackClient, v VideoURL, by Submitter) (VideoDetails, error) {
+	return pls.insert(pbc, v, by, true)
 }
 
-func (pls Playlists) PlayNext(pbc PlaybackClient, vid string, start int) error {
-	if err := validateVideoID(vid); err != nil {
-		return err
+func (pls *Playlists) insert(pbc PlaybackClient, v VideoURL, by Submitter, front bool) (VideoDetails, error) {
+	if err := v.Validate(); err != nil {
+		return VideoDetails{}, err
 	}
 
-	if len(pls[pbc]) == 0 {
-		pls.Add(pbc, vid, start)
-		return nil
+	vid := v.VideoID
+	d := VideoDetails{
+		VideoID:      vid,
+		StartSeconds: v.StartSeconds,
+		EndSeconds:   v.EndSeconds,
+		Loop:         v.Loop,
+		AddedAt:      time.Now(),
+		AddedBy:      by.Name,
+		AddedFrom:    by.Client,
 	}
 
-	if pls[pbc].isDuplicate(vid) {
-		return fmt.Errorf("video already in queue: %s", vid)
+	m, cached := pls.metadata.Cached(vid)
+	if cached {
+		m.apply(&d)
 	}
 
-	d, err := NewDetails(vid, start)
+	cached, err := pls.screen(pbc, &d, cached)
 	if err != nil {
-		return err
+		return d, err
 	}
 
-	n := make([]VideoDetails, 0, len(pls[pbc])+1)
-	n = append(n, d)
-	pls[pbc] = append(n, pls[pbc]...)
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
 
-	return nil
+	id, cur := pls.active(pbc)
+	if id == "" {
+		return d, ErrNoActivePlaylist
+	}
+
+	if i := cur.indexOfVideo(vid); i >= 0 {
+		switch pls.infos[id].Duplicates {
+		case DuplicatesAllow:
+		case DuplicatesMoveToEnd:
+			return pls.moveExisting(id, cur, i, front)
+		default:
+			return d, fmt.Errorf("%w: %s", ErrVideoQueued, vid)
+		}
+	}
+
+	if err := newQueueUsage(pls.settingsFor(pbc.ID).QueueLimits, cur).check(d); err != nil {
+		return d, err
+	}
+
+	i := 0
+	if !front {
+		i = pls.appendIndex(pbc, cur, d)
+	}
+
+	var pl Playlist
+	if i == 0 || i == len(cur) {
+		itemID, err := pls.db.PlaylistItemAdd(id, d, i == 0)
+		if err != nil {
+			return d, fmt.Errorf("Playlists.insert: %w", err)
+		}
+
+		d.ItemID = itemID
+		pl = slices.Insert(slices.Clone(cur), i, d)
+	} else {
+		added, err := pls.db.PlaylistItemAddBatch(id, slices.Insert(slices.Clone(cur), i, d), false)
+		if err != nil {
+			return d, fmt.Errorf("Playlists.insert: %w", err)
+		}
+
+		d = added[i]
+		pl = added
+	}
+
+	pls.set(id, pl)
+	if !cached {
+		pls.metadata.Enqueue(vid)
+	}
+
+	return d, nil
 }
 
-func (pls Playlists) GetNext(pbc PlaybackClient) (VideoDetails, error) {
-	if len(pls) == 0 {
-		return VideoDetails{}, ErrPlaylistEmpty
+// appendIndex returns where a video added to the end of the playback client's playlist goes. In
+// fair and vote-ordered modes the video is placed among the queued videos instead. The caller must
+// hold the lock.
+func (pls *Playlists) appendIndex(pbc PlaybackClient, pl Playlist, d VideoDetails) int {
+	switch pls.settingsFor(pbc.ID).Mode {
+	case ModeFair:
+		return pl.fairIndex(d.AddedBy)
+	case ModeVoteOrdered:
+		return pl.voteIndex(d, pls.voteStart(pbc, pl))
 	}
 
-	if len(pls[pbc]) == 0 {
-		return VideoDetails{}, ErrPlaylistEmpty
+	return len(pl)
+}
+
+// moveExisting moves the item at index i to the front or end of the playlist and returns it. The
+// caller must hold the write lock.
+func (pls *Playlists) moveExisting(id string, cur Playlist, i int, front bool) (VideoDetails, error) {
+	d := cur[i]
+	pl := slices.Delete(slices.Clone(cur), i, i+1)
+	if front {
+		pl = slices.Insert(pl, 0, d)
+	} else {
+		pl = append(pl, d)
+	}
+
+	if err := pls.db.PlaylistItemReorder(id, pl); err != nil {
+		return d, fmt.Errorf("Playlists.moveExisting: %w", err)
 	}
 
-	d := pls[pbc][0]
+	pls.set(id, pl)
 	return d, nil
 }
 
-func (pls Playlists) PeekNext(pbc PlaybackClient) (VideoDetails, error) {
-	if len(pls) < 2 {
+// UpdateMetadata applies the metadata to every queued copy of the video.
+func (pls *Playlists) UpdateMetadata(m VideoMetadata) error {
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	if err := pls.db.PlaylistItemUpdateMetadata(m); err != nil {
+		return fmt.Errorf("Playlists.UpdateMetadata: %w", err)
+	}
+
+	for id, cur := range pls.lists {
+		if !cur.isDuplicate(m.VideoID) {
+			continue
+		}
+
+		pl := slices.Clone(cur)
+		for i := range pl {
+			if pl[i].VideoID == m.VideoID {
+				m.apply(&pl[i])
+			}
+		}
+
+		pls.set(id, pl)
+	}
+
+	return nil
+}
+
+// Settings returns the playback client's settings.
+func (pls *Playlists) Settings(pbc PlaybackClient) PBCSettings {
+	pls.mu.RLock()
+	defer pls.mu.RUnlock()
+
+	return pls.settingsFor(pbc.ID)
+}
+
+// SetMode changes the playback client's playback mode. Switching to ModeFair reorders the
+// playlist so the people who added its videos take turns and switching to ModeVoteOrdered sorts it
+// by score.
+func (pls *Playlists) SetMode(pbc PlaybackClient, mode PlaybackMode) (PBCSettings, error) {
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	st := pls.settingsFor(pbc.ID)
+	st.Mode = mode
+	if err := pls.db.SettingsSave(st); err != nil {
+		return st, fmt.Errorf("Playlists.SetMode: %w", err)
+	}
+
+	if id, cur := pls.active(pbc); len(cur) > 1 && (mode == ModeFair || mode == ModeVoteOrdered) {
+		pl := cur.fairOrder()
+		if mode == ModeVoteOrdered {
+			pl = cur.voteOrder(pls.voteStart(pbc, cur))
+		}
+
+		if err := pls.db.PlaylistItemReorder(id, pl); err != nil {
+			return st, fmt.Errorf("Playlists.SetMode: %w", err)
+		}
+
+		pls.set(id, pl)
+	}
+
+	pls.settings[pbc.ID] = st
+	pls.events.Publish(Event{Type: EventPlaylistUpdated, PBCID: pbc.ID})
+
+	return st, nil
+}
+
+// settingsFor returns the playback client's settings or the defaults if none have been saved. The
+// caller must hold the lock.
+func (pls *Playlists) settingsFor(pbcID string) PBCSettings {
+	if st, ok := pls.settings[pbcID]; ok {
+		return st
+	}
+
+	return NewPBCSettings(pbcID)
+}
+
+// GetNext returns the first video in the playback client's playlist. The first time a video is
+// returned it is marked as playing so its start time can be recorded in the history. In shuffle
+// mode a random video is moved to the front of the playlist when nothing is playing. If the
+// playlist is empty a video from the playback client's fallback source is returned instead, and
+// as soon as a video is queued it is returned in place of the fallback video.
+func (pls *Playlists) GetNext(pbc PlaybackClient) (VideoDetails, error) {
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	return pls.next(pbc)
+}
+
+// next implements GetNext. The caller must hold the write lock.
+func (pls *Playlists) next(pbc PlaybackClient) (VideoDetails, error) {
+	if open, resume := pls.windowOpen(pbc.ID, time.Now()); !open {
+		return VideoDetails{}, outsideWindow(resume)
+	}
+
+	id, pl := pls.active(pbc)
+	if len(pl) == 0 {
+		return pls.nextFallback(pbc)
+	}
+
+	delete(pls.fallbacks, pbc.ID)
+	np, ok := pls.playing[pbc.ID]
+	if !ok && pls.settingsFor(pbc.ID).Mode == ModeShuffle && len(pl) > 1 {
+		i := rand.IntN(len(pl))
+		shuffled := slices.Insert(slices.Delete(slices.Clone(pl), i, i+1), 0, pl[i])
+		if err := pls.db.PlaylistItemReorder(id, shuffled); err != nil {
+			return VideoDetails{}, fmt.Errorf("Playlists.next: %w", err)
+		}
+
+		pls.set(id, shuffled)
+		pl = shuffled
+	}
+
+	if np.ItemID != pl[0].ItemID {
+		pls.playing[pbc.ID] = nowPlaying{ItemID: pl[0].ItemID, StartedAt: time.Now()}
+	}
+
+	return pl[0], nil
+}
+
+// PeekNext returns the video which will play after the current one. In shuffle mode the next
+// video is not picked until the current one ends so ErrEndOfPlaylist is returned. In repeat-one
+// mode, or if the current video loops, the current video is returned.
+func (pls *Playlists) PeekNext(pbc PlaybackClient) (VideoDetails, error) {
+	pls.mu.RLock()
+	defer pls.mu.RUnlock()
+
+	_, pl := pls.active(pbc)
+	switch pls.settingsFor(pbc.ID).Mode {
+	case ModeShuffle:
+		return VideoDetails{}, ErrEndOfPlaylist
+	case ModeRepeatOne:
+		if len(pl) > 0 {
+			return pl[0], nil
+		}
+	case ModeRepeatAll:
+		if len(pl) == 1 {
+			return pl[0], nil
+		}
+	}
+
+	if len(pl) > 0 && pl[0].Loop {
+		return pl[0], nil
+	}
+
+	if len(pl) < 2 {
 		return VideoDetails{}, ErrEndOfPlaylist
 	}
 
-	return pls[pbc][1], nil
+	return pl[1], nil
 }
 
-func (pls Playlists) Remove(pbc PlaybackClient, vid string) error {
-	if len(pls[pbc]) == 0 {
+// Remove moves the item from the playback client's playlist to its trash. If the item was playing
+// it is recorded in the history as skipped.
+func (pls *Playlists) Remove(pbc PlaybackClient, itemID int64) error {
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	return pls.remove(pbc, itemID, HistorySkipped)
+}
+
+// Finish is called once the player has played the video through and records it in the history
+// as finished. What happens to the video depends on the playback mode. In repeat-one mode, or if
+// the video is set to loop, it stays where it is, in repeat-all mode it is moved to the end of the
+// playlist, and otherwise it is removed.
+func (pls *Playlists) Finish(pbc PlaybackClient, itemID int64) error {
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	return pls.remove(pbc, itemID, HistoryFinished)
+}
+
+// remove implements Remove and Finish. The caller must hold the write lock.
+func (pls *Playlists) remove(pbc PlaybackClient, itemID int64, status string) error {
+	if pls.dropFallback(pbc, itemID) {
+		return nil
+	}
+
+	id, cur := pls.active(pbc)
+	if len(cur) == 0 {
 		return ErrPlaylistEmpty
 	}
 
-	for i, d := range pls[pbc] {
-		if d.VideoID == vid {
-			pls[pbc] = append(pls[pbc][:i], pls[pbc][i+1:]...)
-			return nil
+	i := cur.indexOf(itemID)
+	if i < 0 {
+		return fmt.Errorf("%w: %d", ErrItemNotFound, itemID)
+	}
+
+	mode := pls.settingsFor(pbc.ID).Mode
+	switch {
+	case status == HistoryFinished && (mode == ModeRepeatOne || cur[i].Loop):
+	case status == HistoryFinished && mode == ModeRepeatAll:
+		pl := append(slices.Delete(slices.Clone(cur), i, i+1), cur[i])
+		if err := pls.db.PlaylistItemReorder(id, pl); err != nil {
+			return fmt.Errorf("Playlists.remove: %w", err)
 		}
+
+		pls.set(id, pl)
+	case status == HistorySkipped:
+		if err := pls.trash(pbc, id, cur, []int{i}, TrashRemoved); err != nil {
+			return fmt.Errorf("Playlists.remove: %w", err)
+		}
+
+		pls.set(id, slices.Delete(slices.Clone(cur), i, i+1))
+	default:
+		if err := pls.db.PlaylistItemDelete(id, itemID); err != nil {
+			return fmt.Errorf("Playlists.remove: %w", err)
+		}
+
+		pls.set(id, slices.Delete(slices.Clone(cur), i, i+1))
 	}
 
-	return fmt.Errorf("video not found in queue: %s", vid)
+	pls.recordPlayed(pbc, cur[i], status)
+	return nil
 }
 
-func (pls Playlists) Clear(pbc PlaybackClient) {
-	pls[pbc] = make([]VideoDetails, 0)
+// Move moves the item to the target position in the playback client's playlist and returns the
+// new ordering.
+func (pls *Playlists) Move(pbc PlaybackClient, itemID int64, target MoveTarget) (Playlist, error) {
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	id, cur := pls.active(pbc)
+	if id == "" {
+		return nil, ErrNoActivePlaylist
+	}
+
+	pl, err := cur.Move(itemID, target)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pls.db.PlaylistItemReorder(id, pl); err != nil {
+		return nil, fmt.Errorf("Playlists.Move: %w", err)
+	}
+
+	pls.set(id, pl)
+
+	return slices.Clone(pl), nil
+}
+
+// Clear moves every video in the playback client's playlist to its trash. If a video was playing it
+// is recorded in the history as skipped.
+func (pls *Playlists) Clear(pbc PlaybackClient) error {
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	id, cur := pls.active(pbc)
+	if id == "" {
+		return ErrNoActivePlaylist
+	}
+
+	if len(cur) == 0 {
+		return nil
+	}
+
+	indexes := make([]int, len(cur))
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	if err := pls.trash(pbc, id, cur, indexes, TrashCleared); err != nil {
+		return fmt.Errorf("Playlists.Clear: %w", err)
+	}
+
+	pls.set(id, NewPlaylist())
+
+	pls.recordPlayed(pbc, cur[0], HistorySkipped)
+	return nil
+}
+
+// recordPlayed adds d to the history if it is the video the playback client is playing. A video
+// reported as finished is always recorded, even if it was never marked as playing. The video has
+// already left the queue by the time it is recorded, so a failed write is logged rather than
+// failing the change. The caller must hold the write lock.
+func (pls *Playlists) recordPlayed(pbc PlaybackClient, d VideoDetails, status string) {
+	np, ok := pls.playing[pbc.ID]
+	if !ok || np.ItemID != d.ItemID {
+		if status != HistoryFinished {
+			return
+		}
+
+		np = nowPlaying{ItemID: d.ItemID, StartedAt: time.Now()}
+	}
+
+	delete(pls.playing, pbc.ID)
+	entry := HistoryEntry{
+		PBCID:        pbc.ID,
+		VideoDetails: d,
+		StartedAt:    np.StartedAt,
+		EndedAt:      time.Now(),
+		Status:       status,
+	}
+
+	if err := pls.db.HistoryCreate(entry); err != nil {
+		pls.Logger.Printf("Playlists.recordPlayed: %v\n", err)
+	}
 }
@@ -0,0 +1,286 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// newTestDB opens a migrated database in a temporary directory.
+func newTestDB(t *testing.T) *SqliteDB {
+	t.Helper()
+
+	db := openTestDB(t)
+	if err := db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// openTestDB opens an empty database in a temporary directory. The database folder is relative to
+// the working directory so the test moves into the temporary directory until it ends.
+func openTestDB(t *testing.T) *SqliteDB {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	db, err := NewSqliteDB("test.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// newTestPlaylists returns an empty playlist store and a registered playback client.
+func newTestPlaylists(t *testing.T) (*Playlists, PlaybackClient) {
+	t.Helper()
+
+	pls := NewPlaylists(log.New(io.Discard, "", 0), newTestDB(t), nil, NewBroker())
+	pbc, err := pls.Register("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pls, pbc
+}
+
+func testVideoID(i int) string {
+	return fmt.Sprintf("video%06d", i)
+}
+
+// checkPersisted fails the test if the cached playlist doesn't match the one in the database.
+func checkPersisted(t *testing.T, pls *Playlists, pbc PlaybackClient) {
+	t.Helper()
+
+	cached, _ := pls.Get(pbc)
+	stored, err := pls.db.PlaylistItemList(pbc.PlaylistID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(pl Playlist) []int64 {
+		list := make([]int64, 0, len(pl))
+		for _, d := range pl {
+			list = append(list, d.ItemID)
+		}
+
+		return list
+	}
+
+	if !slices.Equal(ids(cached), ids(stored)) {
+		t.Fatalf("cached playlist %v does not match stored playlist %v", ids(cached), ids(stored))
+	}
+}
+
+func TestPlaylistsConcurrentAdd(t *testing.T) {
+	pls, pbc := newTestPlaylists(t)
+
+	const n = 50
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := pls.Add(pbc, VideoURL{VideoID: testVideoID(i)}, Submitter{Name: "test"}); err != nil {
+				t.Errorf("Add(%d): %v", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	pl, _ := pls.Get(pbc)
+	if len(pl) != n {
+		t.Fatalf("got %d videos, want %d", len(pl), n)
+	}
+
+	seen := make(map[int64]bool)
+	for _, d := range pl {
+		if seen[d.ItemID] {
+			t.Fatalf("item %d queued twice", d.ItemID)
+		}
+
+		seen[d.ItemID] = true
+	}
+
+	checkPersisted(t, pls, pbc)
+}
+
+func TestPlaylistsConcurrentAddDuplicate(t *testing.T) {
+	pls, pbc := newTestPlaylists(t)
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := pls.Add(pbc, VideoURL{VideoID: testVideoID(1)}, Submitter{Name: "test"})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	added := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			added++
+		case !errors.Is(err, ErrVideoQueued):
+			t.Errorf("Add: %v", err)
+		}
+	}
+
+	if added != 1 {
+		t.Fatalf("video added %d times, want 1", added)
+	}
+
+	checkPersisted(t, pls, pbc)
+}
+
+func TestPlaylistsConcurrentMutations(t *testing.T) {
+	pls, pbc := newTestPlaylists(t)
+
+	const n = 40
+	for i := range n {
+		if _, err := pls.Add(pbc, VideoURL{VideoID: testVideoID(i)}, Submitter{Name: "test"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Errors which only mean another goroutine got there first.
+	expected := func(err error) bool {
+		return err == nil ||
+			errors.Is(err, ErrItemNotFound) ||
+			errors.Is(err, ErrPlaylistEmpty) ||
+			errors.Is(err, ErrVideoQueued)
+	}
+
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			if _, err := pls.Add(pbc, VideoURL{VideoID: testVideoID(n + i)}, Submitter{Name: "test"}); !expected(err) {
+				t.Errorf("Add: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			pl, _ := pls.Get(pbc)
+			if len(pl) == 0 {
+				return
+			}
+
+			if err := pls.Remove(pbc, pl[len(pl)/2].ItemID); !expected(err) {
+				t.Errorf("Remove: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			d, err := pls.GetNext(pbc)
+			if !expected(err) {
+				t.Errorf("GetNext: %v", err)
+				return
+			}
+
+			if err == nil {
+				if err := pls.Finish(pbc, d.ItemID); !expected(err) {
+					t.Errorf("Finish: %v", err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if i%10 != 0 {
+				return
+			}
+
+			if err := pls.Clear(pbc); !expected(err) {
+				t.Errorf("Clear: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	checkPersisted(t, pls, pbc)
+}
+
+func TestPlaylistsConcurrentRegister(t *testing.T) {
+	pls := NewPlaylists(log.New(io.Discard, "", 0), newTestDB(t), nil, nil)
+
+	const n = 20
+	ids := make([]string, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pbc, err := pls.Register("shared")
+			if err != nil {
+				t.Errorf("Register: %v", err)
+				return
+			}
+
+			ids[i] = pbc.ID
+		}()
+	}
+	wg.Wait()
+
+	if ids[0] == "" || slices.ContainsFunc(ids, func(id string) bool { return id != ids[0] }) {
+		t.Fatalf("Register returned more than one playback client: %v", ids)
+	}
+}
+
+func TestRemoveHandlerUnknownItem(t *testing.T) {
+	pls, pbc := newTestPlaylists(t)
+	d, err := pls.Add(pbc, VideoURL{VideoID: testVideoID(1)}, Submitter{Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewHTTPServer(pls.Logger, "", 0, "", "", pls, nil, nil, nil, nil, pls.db)
+	s.AddRoutes()
+
+	remove := func(itemID int64) int {
+		w := httptest.NewRecorder()
+		u := fmt.Sprintf("/playlists/%s/items/%d", pbc.ID, itemID)
+		s.Mux.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, u, nil))
+		return w.Code
+	}
+
+	if code := remove(d.ItemID + 100); code != http.StatusNotFound {
+		t.Fatalf("got status %d for an unknown item, want %d", code, http.StatusNotFound)
+	}
+
+	if code := remove(d.ItemID); code != http.StatusNoContent {
+		t.Fatalf("got status %d, want %d", code, http.StatusNoContent)
+	}
+
+	if code := remove(d.ItemID); code != http.StatusNotFound {
+		t.Fatalf("got status %d removing from an empty playlist, want %d", code, http.StatusNotFound)
+	}
+}
@@ -15,10 +15,21 @@ import (
 	"github.com/felixge/httpsnoop"
 )
 
+// maxSubmitterLength is the longest nickname RequestSubmitter accepts.
+const maxSubmitterLength = 64
+
+// reasonHeader says why a request got a 204 No Content response, such as the playback client being
+// outside its playback windows.
+const reasonHeader = "X-Ytqueuer-Reason"
+
 type HTTPServer struct {
 	Addr   string
 	Logger *log.Logger
-	Playlists
+	*Playlists
+	Events      *Broker
+	Schedules   *Scheduler
+	Metadata    *MetadataCache
+	Fetcher     PlaylistFetcher
 	DB          *SqliteDB
 	TLSCertFile string
 	TLSKeyFile  string
@@ -37,7 +48,11 @@ func NewHTTPServer(
 	port int,
 	certFile string,
 	keyFile string,
-	playlists Playlists,
+	playlists *Playlists,
+	events *Broker,
+	schedules *Scheduler,
+	metadata *MetadataCache,
+	fetcher PlaylistFetcher,
 	db *SqliteDB,
 ) HTTPServer {
 	mux := http.NewServeMux()
@@ -45,6 +60,10 @@ func NewHTTPServer(
 		Addr:        net.JoinHostPort(addr, strconv.Itoa(port)),
 		Logger:      logger,
 		Playlists:   playlists,
+		Events:      events,
+		Schedules:   schedules,
+		Metadata:    metadata,
+		Fetcher:     fetcher,
 		DB:          db,
 		TLSCertFile: certFile,
 		TLSKeyFile:  keyFile,
@@ -58,6 +77,11 @@ func (s *HTTPServer) Start() error {
 		Handler: s.Handler,
 	}
 
+	// Close event streams on shutdown so open connections don't hold up the server.
+	if s.Events != nil {
+		s.Server.RegisterOnShutdown(s.Events.Close)
+	}
+
 	// Start the server.
 	s.Logger.Printf("http server listening on %s\n", s.Server.Addr)
 	// err := httpServer.ListenAndServe()
@@ -133,6 +157,24 @@ func ClientIP(r *http.Request) string {
 	return remoteAddr(r)
 }
 
+// RequestSubmitter returns who is adding videos in the request. The name is the nickname from the
+// by query parameter, cut to 64 characters, or the client's IP address if no nickname was given.
+// The client is always the client's IP address so changing nicknames doesn't get around the
+// per-submitter queue limit.
+func RequestSubmitter(r *http.Request) Submitter {
+	ip := ClientIP(r)
+	by := strings.TrimSpace(r.URL.Query().Get("by"))
+	if by == "" {
+		return Submitter{Name: ip, Client: ip}
+	}
+
+	if runes := []rune(by); len(runes) > maxSubmitterLength {
+		by = string(runes[:maxSubmitterLength])
+	}
+
+	return Submitter{Name: by, Client: ip}
+}
+
 // remoteAddr returns the remote address from the request without the port.
 func remoteAddr(r *http.Request) string {
 	addr := r.RemoteAddr
@@ -10,6 +10,14 @@ import (
 	"net/http"
 	"os/exec"
 	"strconv"
+	"time"
+)
+
+const (
+	eventKeepAlive = 30 * time.Second
+
+	// maxBodySize caps the size of JSON request bodies.
+	maxBodySize = 1 << 20
 )
 
 func (s *HTTPServer) AddRoutes() {
@@ -21,22 +29,136 @@ func (s *HTTPServer) AddRoutes() {
 	// ---- Playback Client Routes ----
 	s.Mux.Handle("GET /pbcs", mwLogger(s.PBCListHandler()))
 	s.Mux.Handle("POST /pbcs/register", mwLogger(s.PBCRegisterHandler())) // ?name="playback client name"
+	s.Mux.Handle(
+		"PUT /pbcs/{pbcID}/playlist",
+		mwLogger(s.PBCSetPlaylistHandler()),
+	) // ?id=<playlist id>
+
+	// ---- Named Playlist Routes ----
+	// These manage playlists directly. The /playlists routes below work on a playback client's
+	// active playlist.
+	s.Mux.Handle("GET /lists", mwLogger(s.ListsHandler()))
+	s.Mux.Handle("POST /lists", mwLogger(s.ListCreateHandler())) // ?name=<playlist name>
+	s.Mux.Handle("GET /lists/{playlistID}", mwLogger(s.ListGetHandler()))
+	s.Mux.Handle("PUT /lists/{playlistID}", mwLogger(s.ListRenameHandler())) // ?name=<playlist name>
+	s.Mux.Handle("DELETE /lists/{playlistID}", mwLogger(s.ListDeleteHandler()))
+	s.Mux.Handle(
+		"PUT /lists/{playlistID}/duplicates",
+		mwLogger(s.ListDuplicatesHandler()),
+	) // ?policy=<reject|allow|move-to-end>
 
 	// ---- Playlist Routes ----
+	// Routes which add videos also take ?by=<nickname> to record who added them. Without it the
+	// client's IP address is used. Queue limits per submitter always count by IP address.
 	s.Mux.Handle("GET /playlists", mwLogger(s.PlaylistsHandler()))
 	s.Mux.Handle("GET /playlists/{pbcID}", mwLogger(s.PlaylistHandler()))
+	s.Mux.Handle(
+		"POST /playlists/{pbcID}",
+		mwLogger(s.AddURLHandler()),
+	) // ?url=<youtube video url>&next=<true to play next>
 	s.Mux.Handle(
 		"POST /playlists/{pbcID}/{video_id}",
 		mwLogger(s.AddHandler(false)),
-	) // ?start=<start time in seconds>
+	) // ?start=<start time in seconds>&end=<end time in seconds>&loop=<true to loop the clip>
 	s.Mux.Handle(
 		"POST /playlists/{pbcID}/{video_id}/next",
 		mwLogger(s.AddHandler(true)),
-	) // ?start=<start time in seconds>
+	) // ?start=<start time in seconds>&end=<end time in seconds>&loop=<true to loop the clip>
+	s.Mux.Handle("GET /playlists/{pbcID}/events", mwLogger(s.EventsHandler()))
 	s.Mux.Handle("GET /playlists/{pbcID}/next", mwLogger(s.NextHandler(false)))
 	s.Mux.Handle("GET /playlists/{pbcID}/peek", mwLogger(s.NextHandler(true)))
-	s.Mux.Handle("DELETE /playlists/{pbcID}/{video_id}", mwLogger(s.RemoveHandler()))
+	s.Mux.Handle(
+		"POST /playlists/{pbcID}/advance",
+		mwLogger(s.AdvanceHandler()),
+	) // ?token=<unique request token>&current=<item id that just finished>
+	s.Mux.Handle(
+		"DELETE /playlists/{pbcID}/items/{itemID}",
+		mwLogger(s.RemoveHandler()),
+	) // ?finished=<true if the video played through>
+	s.Mux.Handle(
+		"PATCH /playlists/{pbcID}/items/{itemID}",
+		mwLogger(s.MoveHandler()),
+	) // ?index=<new position>|before=<item id>|after=<item id>
+	s.Mux.Handle(
+		"PUT /playlists/{pbcID}/items/{itemID}/vote",
+		mwLogger(s.VoteHandler(false)),
+	) // ?vote=<up|down>
+	s.Mux.Handle("DELETE /playlists/{pbcID}/items/{itemID}/vote", mwLogger(s.VoteHandler(true)))
+	s.Mux.Handle(
+		"PUT /playlists/{pbcID}/items/{itemID}/duration",
+		mwLogger(s.DurationHandler()),
+	) // ?seconds=<length of the video as reported by the player>
+	s.Mux.Handle("GET /playlists/{pbcID}/runtime", mwLogger(s.RuntimeHandler()))
 	s.Mux.Handle("DELETE /playlists/{pbcID}", mwLogger(s.ClearHandler()))
+	s.Mux.Handle("GET /playlists/{pbcID}/trash", mwLogger(s.TrashHandler()))
+	s.Mux.Handle("POST /playlists/{pbcID}/undo", mwLogger(s.UndoHandler()))
+	s.Mux.Handle(
+		"GET /playlists/{pbcID}/history",
+		mwLogger(s.HistoryHandler()),
+	) // ?page=<page number>&per_page=<entries per page>
+	s.Mux.Handle(
+		"POST /playlists/{pbcID}/requeue",
+		mwLogger(s.RequeueHandler()),
+	) // ?entry=<history entry id>&next=<true to play next>
+	s.Mux.Handle("GET /playlists/{pbcID}/mode", mwLogger(s.ModeHandler()))
+	s.Mux.Handle(
+		"PUT /playlists/{pbcID}/mode",
+		mwLogger(s.SetModeHandler()),
+	) // ?mode=<normal|shuffle|repeat-one|repeat-all|fair|vote-ordered>
+	s.Mux.Handle("GET /playlists/{pbcID}/limits", mwLogger(s.LimitsHandler()))
+	s.Mux.Handle(
+		"PUT /playlists/{pbcID}/limits",
+		mwLogger(s.SetLimitsHandler()),
+	) // ?max_length=<videos>&max_per_submitter=<videos>&max_duration=<seconds or 1h2m3s>, 0 for no limit
+	s.Mux.Handle("GET /playlists/{pbcID}/fallback", mwLogger(s.FallbackHandler()))
+	s.Mux.Handle(
+		"PUT /playlists/{pbcID}/fallback",
+		mwLogger(s.SetFallbackHandler()),
+	) // ?source=<none|playlist|history|favorites>&playlist=<saved playlist id>
+	s.Mux.Handle("GET /playlists/{pbcID}/filters", mwLogger(s.FiltersHandler()))
+	s.Mux.Handle(
+		"POST /playlists/{pbcID}/filters",
+		mwLogger(s.FilterAddHandler()),
+	) // ?kind=<video|channel|keyword>&action=<allow|block>&value=<video id or url, channel, or keyword>
+	s.Mux.Handle(
+		"PUT /playlists/{pbcID}/filters",
+		mwLogger(s.AllowlistOnlyHandler()),
+	) // ?allowlist_only=<true to only queue videos an allow rule matches>
+	s.Mux.Handle("DELETE /playlists/{pbcID}/filters/{ruleID}", mwLogger(s.FilterDeleteHandler()))
+	s.Mux.Handle(
+		"GET /playlists/{pbcID}/blocked",
+		mwLogger(s.BlockedHandler()),
+	) // ?page=<page number>&per_page=<entries per page>
+	s.Mux.Handle("GET /playlists/{pbcID}/windows", mwLogger(s.WindowsHandler()))
+	s.Mux.Handle(
+		"POST /playlists/{pbcID}/windows",
+		mwLogger(s.WindowAddHandler()),
+	) // ?start=<07:00>&end=<21:00>&days=<mon-fri>&tz=<time zone>&standby=<true to turn the TV off at end>
+	s.Mux.Handle("DELETE /playlists/{pbcID}/windows/{windowID}", mwLogger(s.WindowDeleteHandler()))
+	s.Mux.Handle(
+		"POST /playlists/{pbcID}/items",
+		mwLogger(s.BulkAddHandler()),
+	) // ?position=<index to insert at>, body: JSON array of video IDs, URLs, or objects
+	s.Mux.Handle(
+		"POST /playlists/{pbcID}/import",
+		mwLogger(s.ImportHandler()),
+	) // ?url=<youtube playlist url> or ?format=<json|m3u|csv>&mode=<append|replace> with a file body
+	s.Mux.Handle("GET /playlists/{pbcID}/export", mwLogger(s.ExportHandler())) // ?format=<json|m3u|csv>
+
+	// ---- Schedule Routes ----
+	// Schedules queue videos or switch a playback client's playlist at set times. Create and update
+	// take a JSON ScheduleRequest body.
+	s.Mux.Handle("GET /schedules", mwLogger(s.ScheduleListHandler())) // ?pbc=<playback client id>
+	s.Mux.Handle("POST /schedules", mwLogger(s.ScheduleCreateHandler()))
+	s.Mux.Handle("GET /schedules/{scheduleID}", mwLogger(s.ScheduleGetHandler()))
+	s.Mux.Handle("PUT /schedules/{scheduleID}", mwLogger(s.ScheduleUpdateHandler()))
+	s.Mux.Handle("DELETE /schedules/{scheduleID}", mwLogger(s.ScheduleDeleteHandler()))
+
+	// ---- Video Metadata Routes ----
+	s.Mux.Handle("GET /metadata/{video_id}", mwLogger(s.MetadataGetHandler()))
+	s.Mux.Handle("POST /metadata/{video_id}/refresh", mwLogger(s.MetadataRefreshHandler()))
+	s.Mux.Handle("DELETE /metadata/{video_id}", mwLogger(s.MetadataDeleteHandler()))
+	s.Mux.Handle("DELETE /metadata", mwLogger(s.MetadataPurgeHandler())) // ?expired=<true to only purge expired entries>
 
 	// ---- Wake On LAN Routes ----
 	// s.Mux.Handle("GET /wol", mwLogger(s.WakeHandler()))
@@ -84,7 +206,9 @@ func RenderHTML(w http.ResponseWriter, status int, s string) error {
 }
 
 func RenderError(w http.ResponseWriter, msg string, status int) {
-	if err := RenderJSON(w, status, struct{ message string }{message: msg}); err != nil {
+	if err := RenderJSON(w, status, struct {
+		Message string `json:"message"`
+	}{Message: msg}); err != nil {
 		log.Printf("error rendering json: %v\n", err)
 		http.Error(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
 	}
@@ -97,7 +221,7 @@ func (s *HTTPServer) GetPBC(w http.ResponseWriter, r *http.Request) (PlaybackCli
 		return PlaybackClient{}, fmt.Errorf("pbcID is empty")
 	}
 
-	pbc, _, err := s.DB.PlaylistGet(pbcID)
+	pbc, err := s.DB.PlaybackClientGet(pbcID)
 	if err != nil {
 		RenderError(w, "pbcID not found", http.StatusNotFound)
 		return PlaybackClient{}, fmt.Errorf("pbcID not found: %s", pbcID)
@@ -106,10 +230,22 @@ func (s *HTTPServer) GetPBC(w http.ResponseWriter, r *http.Request) (PlaybackCli
 	return pbc, nil
 }
 
-func (s *HTTPServer) PlaylistsHandler() http.Handler {
-	pbcs := s.Playlists.GetPBCs()
+// GetItemID returns the item ID from the request path. If the ID is missing or invalid a 400 Bad
+// Request response is sent and an error is returned.
+func (s *HTTPServer) GetItemID(w http.ResponseWriter, r *http.Request) (int64, error) {
+	// Fallback videos have negative item IDs.
+	itemID, err := strconv.ParseInt(r.PathValue("itemID"), 10, 64)
+	if err != nil || itemID == 0 {
+		RenderError(w, "invalid item ID", http.StatusBadRequest)
+		return 0, fmt.Errorf("invalid item ID: %q", r.PathValue("itemID"))
+	}
 
+	return itemID, nil
+}
+
+func (s *HTTPServer) PlaylistsHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbcs := s.Playlists.GetPBCs()
 		if err := RenderJSON(w, http.StatusOK, pbcs); err != nil {
 			s.Logger.Printf("error rendering json: %v\n", err)
 			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
@@ -158,27 +294,11 @@ func (s *HTTPServer) PBCRegisterHandler() http.Handler {
 			return
 		}
 
-		pbc, pl, err := s.DB.PlaylistGetByName(name)
-		if err == nil {
-			if _, ok := s.Playlists[pbc]; !ok {
-				s.Playlists[pbc] = pl
-			}
-		} else {
-			pbc, err = NewPlaybackClient(name)
-			if err != nil {
-				s.Logger.Printf("error creating playback client: %v\n", err)
-				RenderError(w, fmt.Sprintf("error creating playback client: %v", err), http.StatusBadRequest)
-			}
-
-			pl = NewPlaylist()
-			s.Playlists[pbc] = pl
-
-			err := s.DB.PlaylistCreate(pbc, s.Playlists[pbc])
-			if err != nil {
-				s.Logger.Printf("error registering playback client: %v\n", err)
-				RenderError(w, fmt.Sprintf("error registering playback client: %v", err), http.StatusInternalServerError)
-				return
-			}
+		pbc, err := s.Playlists.Register(name)
+		if err != nil {
+			s.Logger.Printf("error registering playback client: %v\n", err)
+			RenderError(w, fmt.Sprintf("error registering playback client: %v", err), http.StatusBadRequest)
+			return
 		}
 
 		/*
@@ -200,7 +320,164 @@ func (s *HTTPServer) PBCRegisterHandler() http.Handler {
 	})
 }
 
-// PlaylistHandler returns a http.Handler that lists the current playlist for the provided playback client ID.
+// PBCSetPlaylistHandler switches the playback client to play from another playlist.
+func (s *HTTPServer) PBCSetPlaylistHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		pbc, err = s.Playlists.SetActivePlaylist(pbc, r.URL.Query().Get("id"))
+		if err != nil {
+			if errors.Is(err, ErrUnknownPlaylist) {
+				RenderError(w, "playlist not found", http.StatusNotFound)
+				return
+			}
+
+			s.Logger.Printf("error setting active playlist: %v\n", err)
+			RenderError(w, fmt.Sprintf("error setting active playlist: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, pbc); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ############################################################################################## //
+// ################################    Named Playlist Handlers    ############################### //
+// ############################################################################################## //
+
+// ListsHandler returns a http.Handler that lists every named playlist.
+func (s *HTTPServer) ListsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := RenderJSON(w, http.StatusOK, s.Playlists.ListPlaylists()); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ListCreateHandler returns a http.Handler that creates a new, empty named playlist.
+func (s *HTTPServer) ListCreateHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, err := s.Playlists.CreatePlaylist(r.URL.Query().Get("name"))
+		if err != nil {
+			s.Logger.Printf("error creating playlist: %v\n", err)
+			RenderError(w, fmt.Sprintf("error creating playlist: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusCreated, info); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ListGetHandler returns a http.Handler that returns a named playlist and the videos in it.
+func (s *HTTPServer) ListGetHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, pl, ok := s.Playlists.GetPlaylist(r.PathValue("playlistID"))
+		if !ok {
+			RenderError(w, "playlist not found", http.StatusNotFound)
+			return
+		}
+
+		msg := struct {
+			PlaylistInfo
+			Videos Playlist `json:"videos"`
+		}{
+			PlaylistInfo: info,
+			Videos:       pl,
+		}
+
+		if err := RenderJSON(w, http.StatusOK, msg); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ListRenameHandler returns a http.Handler that renames a named playlist.
+func (s *HTTPServer) ListRenameHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info, err := s.Playlists.RenamePlaylist(r.PathValue("playlistID"), r.URL.Query().Get("name"))
+		if err != nil {
+			if errors.Is(err, ErrUnknownPlaylist) {
+				RenderError(w, "playlist not found", http.StatusNotFound)
+				return
+			}
+
+			s.Logger.Printf("error renaming playlist: %v\n", err)
+			RenderError(w, fmt.Sprintf("error renaming playlist: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, info); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ListDuplicatesHandler returns a http.Handler that sets how a named playlist handles videos which
+// are already queued.
+func (s *HTTPServer) ListDuplicatesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		policy, err := ParseDuplicatePolicy(r.URL.Query().Get("policy"))
+		if err != nil {
+			RenderError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		info, err := s.Playlists.SetDuplicatePolicy(r.PathValue("playlistID"), policy)
+		if err != nil {
+			if errors.Is(err, ErrUnknownPlaylist) {
+				RenderError(w, "playlist not found", http.StatusNotFound)
+				return
+			}
+
+			s.Logger.Printf("error setting duplicate policy: %v\n", err)
+			RenderError(w, fmt.Sprintf("error setting duplicate policy: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, info); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ListDeleteHandler returns a http.Handler that deletes a named playlist. Playlists which are in
+// use can't be deleted.
+func (s *HTTPServer) ListDeleteHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := s.Playlists.DeletePlaylist(r.PathValue("playlistID")); err != nil {
+			switch {
+			case errors.Is(err, ErrUnknownPlaylist):
+				RenderError(w, "playlist not found", http.StatusNotFound)
+			case errors.Is(err, ErrPlaylistInUse):
+				RenderError(w, err.Error(), http.StatusConflict)
+			default:
+				s.Logger.Printf("error deleting playlist: %v\n", err)
+				RenderError(w, fmt.Sprintf("error deleting playlist: %v", err), http.StatusInternalServerError)
+			}
+
+			return
+		}
+
+		http.Error(w, "", http.StatusNoContent)
+	})
+}
+
+// PlaylistHandler returns a http.Handler that lists the current playlist for the provided playback
+// client ID. Each video includes when it is expected to start and the response includes the
+// queue's total runtime.
 func (s *HTTPServer) PlaylistHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		pbc, err := s.GetPBC(w, r)
@@ -208,25 +485,77 @@ func (s *HTTPServer) PlaylistHandler() http.Handler {
 			return
 		}
 
-		pl, ok := s.Playlists[pbc]
+		q, ok := s.Playlists.GetQueued(pbc)
 		if !ok {
 			s.Logger.Printf("error getting playlist: playlist not found\n")
 			http.Error(w, "", http.StatusNotFound)
 			return
 		}
 
-		if len(pl) == 0 {
+		if len(q.Videos) == 0 {
 			http.Error(w, "", http.StatusNoContent)
 			return
 		}
 
-		if err := RenderJSON(w, http.StatusOK, pl); err != nil {
+		if err := RenderJSON(w, http.StatusOK, q); err != nil {
 			s.Logger.Printf("error rendering json: %v\n", err)
 			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
 		}
 	})
 }
 
+// EventsHandler returns a http.Handler that streams playlist events for the provided playback
+// client ID as server-sent events. A comment is sent every eventKeepAlive to keep idle connections
+// open.
+func (s *HTTPServer) EventsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		rc := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		if err := rc.Flush(); err != nil {
+			s.Logger.Printf("error streaming events: %v\n", err)
+			return
+		}
+
+		events, cancel := s.Events.Subscribe(pbc.ID)
+		defer cancel()
+
+		keepAlive := time.NewTicker(eventKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+
+				data, err := json.Marshal(e)
+				if err != nil {
+					s.Logger.Printf("error marshalling event: %v\n", err)
+					continue
+				}
+
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			}
+
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	})
+}
+
 // AddHandler returns a http.Handler that adds a video to the playback client playlist for the
 // provided playback client ID. If next is true, the video is added to the beginning of the
 // playlist.
@@ -240,47 +569,105 @@ func (s *HTTPServer) AddHandler(next bool) http.Handler {
 		// Get the video ID from the path.
 		vid := r.PathValue("video_id")
 
-		// Get the start time, in seconds, from the query string.
-		start := 0
-		startRaw := r.URL.Query().Get("start")
-		// Skip this step if startRaw is an empty string.
-		if startRaw != "" {
-			// If startRaw can be successfully converted to an integer, set start to that value.
-			if si, err := strconv.Atoi(startRaw); err == nil {
-				start = si
-			}
+		v := VideoURL{VideoID: vid}
+
+		// Get the start and end times, in seconds, from the query string. Values which are not
+		// integers are ignored.
+		if si, err := strconv.Atoi(r.URL.Query().Get("start")); err == nil {
+			v.StartSeconds = si
+		}
+
+		if ei, err := strconv.Atoi(r.URL.Query().Get("end")); err == nil {
+			v.EndSeconds = ei
 		}
 
+		v.Loop, _ = strconv.ParseBool(r.URL.Query().Get("loop"))
+
 		if next {
-			if err := s.Playlists.PlayNext(pbc, vid, start); err != nil {
+			if _, err := s.Playlists.PlayNext(pbc, v, RequestSubmitter(r)); err != nil {
+				if status, ok := rejectStatus(err); ok {
+					RenderError(w, err.Error(), status)
+					return
+				}
+
 				s.Logger.Printf("error adding video to playlist: %v\n", err)
 				RenderError(w, fmt.Sprintf("error adding video to playlist: %v", err), http.StatusBadRequest)
 				return
 			}
 		} else {
 			// Add the video to the playlist. If there is an error, send a 400 Bad Request response.
-			if err := s.Playlists.Add(pbc, vid, start); err != nil {
+			if _, err := s.Playlists.Add(pbc, v, RequestSubmitter(r)); err != nil {
+				if status, ok := rejectStatus(err); ok {
+					RenderError(w, err.Error(), status)
+					return
+				}
+
 				s.Logger.Printf("error adding video to playlist: %v\n", err)
 				RenderError(w, fmt.Sprintf("error adding video to playlist: %v", err), http.StatusBadRequest)
 				return
 			}
 		}
 
-		// Write playlist
-		err = s.Playlists.Save(s.DB, pbc)
+		pl, _ := s.Playlists.Get(pbc)
+
+		// Send a JSON response.
+		msg := struct {
+			Message  string   `json:"message"`
+			Playlist Playlist `json:"playlist"`
+		}{
+			Message:  "video added to playlist",
+			Playlist: pl,
+		}
+
+		if err := RenderJSON(w, http.StatusOK, msg); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// AddURLHandler adds the video from a YouTube URL to the playlist. The start time, end time, and
+// loop flag are taken from the URL.
+func (s *HTTPServer) AddURLHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
 		if err != nil {
-			s.Logger.Printf("error saving playlist: %v\n", err)
-			RenderError(w, fmt.Sprintf("error saving playlist: %v", err), http.StatusInternalServerError)
 			return
 		}
 
-		// Send a JSON response.
+		v, err := ParseVideoURL(r.URL.Query().Get("url"))
+		if err != nil {
+			RenderError(w, fmt.Sprintf("invalid url: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		next, _ := strconv.ParseBool(r.URL.Query().Get("next"))
+		if next {
+			_, err = s.Playlists.PlayNext(pbc, v, RequestSubmitter(r))
+		} else {
+			_, err = s.Playlists.Add(pbc, v, RequestSubmitter(r))
+		}
+
+		if err != nil {
+			if status, ok := rejectStatus(err); ok {
+				RenderError(w, err.Error(), status)
+				return
+			}
+
+			s.Logger.Printf("error adding video to playlist: %v\n", err)
+			RenderError(w, fmt.Sprintf("error adding video to playlist: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		pl, _ := s.Playlists.Get(pbc)
 		msg := struct {
 			Message  string   `json:"message"`
+			Video    VideoURL `json:"video"`
 			Playlist Playlist `json:"playlist"`
 		}{
 			Message:  "video added to playlist",
-			Playlist: s.Playlists[pbc],
+			Video:    v,
+			Playlist: pl,
 		}
 
 		if err := RenderJSON(w, http.StatusOK, msg); err != nil {
@@ -313,6 +700,12 @@ func (s *HTTPServer) NextHandler(peek bool) http.Handler {
 				return
 			}
 
+			if errors.Is(err, ErrOutsideWindow) {
+				w.Header().Set(reasonHeader, err.Error())
+				http.Error(w, "", http.StatusNoContent)
+				return
+			}
+
 			s.Logger.Printf("error getting next video: %v\n", err)
 			RenderError(w, fmt.Sprintf("error getting next video: %v", err), http.StatusInternalServerError)
 			return
@@ -326,59 +719,1437 @@ func (s *HTTPServer) NextHandler(peek bool) http.Handler {
 	})
 }
 
-// RemoveHandler returns a http.Handler that removes a video from the playlist for the provided
-// playback client ID.
-func (s *HTTPServer) RemoveHandler() http.Handler {
+// AdvanceHandler finishes the playing video and returns the next one. Players should use this
+// instead of removing the video and then asking for the next one so players sharing a playback
+// client can't skip videos. A retried request must reuse its token.
+func (s *HTTPServer) AdvanceHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		pbc, err := s.GetPBC(w, r)
 		if err != nil {
 			return
 		}
 
-		vid := r.PathValue("video_id")
-		if vid == "" {
-			s.Logger.Printf("video ID is empty\n")
-			RenderError(w, "video ID is empty", http.StatusBadRequest)
+		var current int64
+		q := r.URL.Query()
+		if c := q.Get("current"); c != "" {
+			if current, err = strconv.ParseInt(c, 10, 64); err != nil {
+				RenderError(w, "invalid current item ID", http.StatusBadRequest)
+				return
+			}
+		}
+
+		d, err := s.Playlists.Advance(pbc, current, q.Get("token"))
+		if err != nil {
+			if errors.Is(err, ErrPlaylistEmpty) {
+				http.Error(w, "", http.StatusNoContent)
+				return
+			}
+
+			if errors.Is(err, ErrOutsideWindow) {
+				w.Header().Set(reasonHeader, err.Error())
+				http.Error(w, "", http.StatusNoContent)
+				return
+			}
+
+			if errors.Is(err, ErrTokenEmpty) {
+				RenderError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			s.Logger.Printf("error advancing playlist: %v\n", err)
+			RenderError(w, fmt.Sprintf("error advancing playlist: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, d); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// RemoveHandler returns a http.Handler that removes an item from the playlist for the provided
+// playback client ID. Players set finished=true once a video has played through so the history can
+// tell finished videos from skipped ones.
+func (s *HTTPServer) RemoveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
 			return
 		}
 
-		err = s.Playlists.Remove(pbc, vid)
+		itemID, err := s.GetItemID(w, r)
 		if err != nil {
-			s.Logger.Printf("error removing video from playlist: %v\n", err)
-			RenderError(w, fmt.Sprintf("error removing video from playlist: %v", err), http.StatusBadRequest)
 			return
 		}
 
-		http.Error(w, "", http.StatusNoContent)
+		finished, _ := strconv.ParseBool(r.URL.Query().Get("finished"))
+		if finished {
+			err = s.Playlists.Finish(pbc, itemID)
+		} else {
+			err = s.Playlists.Remove(pbc, itemID)
+		}
 
-		// Write playlist
-		err = s.Playlists.Save(s.DB, pbc)
 		if err != nil {
-			s.Logger.Printf("error saving playlist: %v\n", err)
-			RenderError(w, fmt.Sprintf("error saving playlist: %v", err), http.StatusInternalServerError)
+			switch {
+			case errors.Is(err, ErrItemNotFound), errors.Is(err, ErrPlaylistEmpty):
+				RenderError(w, err.Error(), http.StatusNotFound)
+			default:
+				s.Logger.Printf("error removing video from playlist: %v\n", err)
+				RenderError(w, fmt.Sprintf("error removing video from playlist: %v", err), http.StatusInternalServerError)
+			}
+
 			return
 		}
+
+		http.Error(w, "", http.StatusNoContent)
 	})
 }
 
-// ClearHandler returns a http.Handler that clears the playlist for the provided playback client ID.
-func (s *HTTPServer) ClearHandler() http.Handler {
+// MoveHandler returns a http.Handler that moves an item within the playlist for the provided
+// playback client ID. The new position is given as an index or relative to another item in the
+// playlist. The reordered playlist is returned.
+func (s *HTTPServer) MoveHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		pbc, err := s.GetPBC(w, r)
 		if err != nil {
 			return
 		}
 
-		s.Playlists.Clear(pbc)
-		http.Error(w, "", http.StatusNoContent)
-
-		// Write playlist
-		err = s.Playlists.Save(s.DB, pbc)
+		itemID, err := s.GetItemID(w, r)
 		if err != nil {
-			s.Logger.Printf("error saving playlist: %v\n", err)
-			RenderError(w, fmt.Sprintf("error saving playlist: %v", err), http.StatusInternalServerError)
 			return
 		}
+
+		var target MoveTarget
+		for param, id := range map[string]*int64{"before": &target.Before, "after": &target.After} {
+			raw := r.URL.Query().Get(param)
+			if raw == "" {
+				continue
+			}
+
+			if *id, err = strconv.ParseInt(raw, 10, 64); err != nil {
+				RenderError(w, "invalid "+param, http.StatusBadRequest)
+				return
+			}
+		}
+
+		if idx := r.URL.Query().Get("index"); idx != "" {
+			i, err := strconv.Atoi(idx)
+			if err != nil {
+				RenderError(w, "invalid index", http.StatusBadRequest)
+				return
+			}
+
+			target.Index = &i
+		}
+
+		pl, err := s.Playlists.Move(pbc, itemID, target)
+		if err != nil {
+			s.Logger.Printf("error moving video: %v\n", err)
+			RenderError(w, fmt.Sprintf("error moving video: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		msg := struct {
+			Message  string   `json:"message"`
+			Playlist Playlist `json:"playlist"`
+		}{
+			Message:  "video moved",
+			Playlist: pl,
+		}
+
+		if err := RenderJSON(w, http.StatusOK, msg); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// VoteHandler returns a http.Handler that records a vote on an item in the playlist for the
+// provided playback client ID. Each client has one vote per item. Votes are kept against the
+// client's IP address rather than its nickname so a client can't vote again under another name. If
+// clear is true the client's vote is taken back instead.
+func (s *HTTPServer) VoteHandler(clear bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		itemID, err := s.GetItemID(w, r)
+		if err != nil {
+			return
+		}
+
+		vote := 0
+		if !clear {
+			if vote, err = ParseVote(r.URL.Query().Get("vote")); err != nil {
+				RenderError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		d, err := s.Playlists.Vote(pbc, itemID, ClientIP(r), vote)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrItemNotFound):
+				RenderError(w, err.Error(), http.StatusNotFound)
+			case errors.Is(err, ErrNoActivePlaylist):
+				RenderError(w, err.Error(), http.StatusConflict)
+			default:
+				s.Logger.Printf("error voting on video: %v\n", err)
+				RenderError(w, fmt.Sprintf("error voting on video: %v", err), http.StatusInternalServerError)
+			}
+
+			return
+		}
+
+		pl, _ := s.Playlists.Get(pbc)
+		msg := struct {
+			Message  string       `json:"message"`
+			Item     VideoDetails `json:"item"`
+			Playlist Playlist     `json:"playlist"`
+		}{
+			Message:  "vote recorded",
+			Item:     d,
+			Playlist: pl,
+		}
+
+		if clear {
+			msg.Message = "vote removed"
+		}
+
+		if err := RenderJSON(w, http.StatusOK, msg); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// DurationHandler records the length of a video as reported by the player that is playing it. It
+// is ignored if the video's duration is already known.
+func (s *HTTPServer) DurationHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		itemID, err := s.GetItemID(w, r)
+		if err != nil {
+			return
+		}
+
+		secs, err := strconv.Atoi(r.URL.Query().Get("seconds"))
+		if err != nil {
+			RenderError(w, fmt.Sprintf("invalid seconds: %s", r.URL.Query().Get("seconds")), http.StatusBadRequest)
+			return
+		}
+
+		d, err := s.Playlists.SetDuration(pbc, itemID, secs)
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrInvalidDuration):
+				RenderError(w, err.Error(), http.StatusBadRequest)
+			case errors.Is(err, ErrItemNotFound):
+				RenderError(w, err.Error(), http.StatusNotFound)
+			case errors.Is(err, ErrNoActivePlaylist):
+				RenderError(w, err.Error(), http.StatusConflict)
+			default:
+				s.Logger.Printf("error setting video duration: %v\n", err)
+				RenderError(w, fmt.Sprintf("error setting video duration: %v", err), http.StatusInternalServerError)
+			}
+
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, d); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// RuntimeHandler returns when each video in the playback client's queue is expected to start and
+// how long the queue has left to play.
+func (s *HTTPServer) RuntimeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		rt, err := s.Playlists.Runtime(pbc)
+		if err != nil {
+			if errors.Is(err, ErrNoActivePlaylist) {
+				RenderError(w, err.Error(), http.StatusConflict)
+				return
+			}
+
+			s.Logger.Printf("error getting queue runtime: %v\n", err)
+			RenderError(w, fmt.Sprintf("error getting queue runtime: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, rt); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// TrashHandler returns a http.Handler that lists the videos which can still be restored for the
+// provided playback client ID, newest first.
+func (s *HTTPServer) TrashHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		entries, err := s.Playlists.Trash(pbc)
+		if err != nil {
+			s.Logger.Printf("error getting trash: %v\n", err)
+			RenderError(w, fmt.Sprintf("error getting trash: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if entries == nil {
+			entries = []TrashEntry{}
+		}
+
+		if err := RenderJSON(w, http.StatusOK, entries); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// UndoHandler returns a http.Handler that restores the videos from the last remove or clear for
+// the provided playback client ID.
+func (s *HTTPServer) UndoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		entries, err := s.Playlists.Undo(pbc)
+		if err != nil {
+			if errors.Is(err, ErrTrashEmpty) {
+				RenderError(w, err.Error(), http.StatusNotFound)
+				return
+			}
+
+			s.Logger.Printf("error restoring videos: %v\n", err)
+			RenderError(w, fmt.Sprintf("error restoring videos: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		pl, _ := s.Playlists.Get(pbc)
+		msg := struct {
+			Message  string       `json:"message"`
+			Restored []TrashEntry `json:"restored"`
+			Playlist Playlist     `json:"playlist"`
+		}{
+			Message:  fmt.Sprintf("%d videos restored", len(entries)),
+			Restored: entries,
+			Playlist: pl,
+		}
+
+		if err := RenderJSON(w, http.StatusOK, msg); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ClearHandler returns a http.Handler that clears the playlist for the provided playback client ID.
+func (s *HTTPServer) ClearHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		if err := s.Playlists.Clear(pbc); err != nil {
+			s.Logger.Printf("error clearing playlist: %v\n", err)
+			RenderError(w, fmt.Sprintf("error clearing playlist: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		http.Error(w, "", http.StatusNoContent)
+	})
+}
+
+// HistoryHandler returns a http.Handler that lists the played videos for the provided playback
+// client ID, newest first.
+func (s *HTTPServer) HistoryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		var page, perPage int
+		if p := r.URL.Query().Get("page"); p != "" {
+			if page, err = strconv.Atoi(p); err != nil {
+				RenderError(w, "invalid page", http.StatusBadRequest)
+				return
+			}
+		}
+
+		if pp := r.URL.Query().Get("per_page"); pp != "" {
+			if perPage, err = strconv.Atoi(pp); err != nil {
+				RenderError(w, "invalid per_page", http.StatusBadRequest)
+				return
+			}
+		}
+
+		hp, err := NewHistoryPage(page, perPage)
+		if err != nil {
+			RenderError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		hp, err = s.DB.HistoryList(pbc.ID, hp)
+		if err != nil {
+			s.Logger.Printf("error listing history: %v\n", err)
+			RenderError(w, fmt.Sprintf("error listing history: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, hp); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// RequeueHandler returns a http.Handler that adds a video from the history back onto the playlist
+// for the provided playback client ID. The history entry may come from any playback client.
+func (s *HTTPServer) RequeueHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		id, err := strconv.ParseInt(r.URL.Query().Get("entry"), 10, 64)
+		if err != nil {
+			RenderError(w, "invalid entry", http.StatusBadRequest)
+			return
+		}
+
+		entry, err := s.DB.HistoryGet(id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				RenderError(w, "history entry not found", http.StatusNotFound)
+				return
+			}
+
+			s.Logger.Printf("error getting history entry: %v\n", err)
+			RenderError(w, fmt.Sprintf("error getting history entry: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		v := VideoURL{
+			VideoID:      entry.VideoID,
+			StartSeconds: entry.StartSeconds,
+			EndSeconds:   entry.EndSeconds,
+			Loop:         entry.Loop,
+		}
+		next, _ := strconv.ParseBool(r.URL.Query().Get("next"))
+		if next {
+			_, err = s.Playlists.PlayNext(pbc, v, RequestSubmitter(r))
+		} else {
+			_, err = s.Playlists.Add(pbc, v, RequestSubmitter(r))
+		}
+
+		if err != nil {
+			if status, ok := rejectStatus(err); ok {
+				RenderError(w, err.Error(), status)
+				return
+			}
+
+			s.Logger.Printf("error adding video to playlist: %v\n", err)
+			RenderError(w, fmt.Sprintf("error adding video to playlist: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		pl, _ := s.Playlists.Get(pbc)
+		msg := struct {
+			Message  string   `json:"message"`
+			Playlist Playlist `json:"playlist"`
+		}{
+			Message:  "video added to playlist",
+			Playlist: pl,
+		}
+
+		if err := RenderJSON(w, http.StatusOK, msg); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ModeHandler returns the playback client's settings, including its playback mode.
+func (s *HTTPServer) ModeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, s.Playlists.Settings(pbc)); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// SetModeHandler changes the playback client's playback mode.
+func (s *HTTPServer) SetModeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		mode, err := ParsePlaybackMode(r.URL.Query().Get("mode"))
+		if err != nil {
+			RenderError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		st, err := s.Playlists.SetMode(pbc, mode)
+		if err != nil {
+			s.Logger.Printf("error setting playback mode: %v\n", err)
+			RenderError(w, fmt.Sprintf("error setting playback mode: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, st); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// LimitsHandler returns the playback client's queue limits and how much more its queue will take
+// from the client making the request.
+func (s *HTTPServer) LimitsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		c, err := s.Playlists.Capacity(pbc, RequestSubmitter(r))
+		if err != nil {
+			if errors.Is(err, ErrNoActivePlaylist) {
+				RenderError(w, err.Error(), http.StatusConflict)
+				return
+			}
+
+			s.Logger.Printf("error getting queue capacity: %v\n", err)
+			RenderError(w, fmt.Sprintf("error getting queue capacity: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, c); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// SetLimitsHandler changes the playback client's queue limits. Limits which are not in the query
+// string keep their current value.
+func (s *HTTPServer) SetLimitsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		q := r.URL.Query()
+		limits := s.Playlists.Settings(pbc).QueueLimits
+		for name, limit := range map[string]*int{
+			"max_length":        &limits.MaxLength,
+			"max_per_submitter": &limits.MaxPerSubmitter,
+		} {
+			if !q.Has(name) {
+				continue
+			}
+
+			if *limit, err = strconv.Atoi(q.Get(name)); err != nil {
+				RenderError(w, fmt.Sprintf("invalid %s: %s", name, q.Get(name)), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if q.Has("max_duration") {
+			if limits.MaxDuration, err = ParseTimestamp(q.Get("max_duration")); err != nil {
+				RenderError(w, fmt.Sprintf("invalid max_duration: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if err := limits.Validate(); err != nil {
+			RenderError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		st, err := s.Playlists.SetLimits(pbc, limits)
+		if err != nil {
+			s.Logger.Printf("error setting queue limits: %v\n", err)
+			RenderError(w, fmt.Sprintf("error setting queue limits: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, st); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// FallbackHandler returns where the playback client gets videos from when its queue is empty and
+// the fallback video it is playing, if any.
+func (s *HTTPServer) FallbackHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, s.Playlists.Fallback(pbc)); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// SetFallbackHandler changes where the playback client gets videos from when its queue is empty.
+func (s *HTTPServer) SetFallbackHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		q := r.URL.Query()
+		source, err := ParseFallbackSource(q.Get("source"))
+		if err != nil {
+			RenderError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		st, err := s.Playlists.SetFallback(pbc, source, q.Get("playlist"))
+		if err != nil {
+			if errors.Is(err, ErrUnknownPlaylist) {
+				RenderError(w, "playlist not found", http.StatusNotFound)
+				return
+			}
+
+			s.Logger.Printf("error setting fallback source: %v\n", err)
+			RenderError(w, fmt.Sprintf("error setting fallback source: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, st); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// FiltersHandler returns the playback client's filter rules and whether it is in allowlist-only
+// mode.
+func (s *HTTPServer) FiltersHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, s.Playlists.Filters(pbc)); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// FilterAddHandler adds a filter rule to the playback client.
+func (s *HTTPServer) FilterAddHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		q := r.URL.Query()
+		kind, err := ParseFilterKind(q.Get("kind"))
+		if err != nil {
+			RenderError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		action, err := ParseFilterAction(q.Get("action"))
+		if err != nil {
+			RenderError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rule, err := NewFilterRule(pbc.ID, kind, action, q.Get("value"))
+		if err != nil {
+			RenderError(w, fmt.Sprintf("invalid filter rule: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		rule, err = s.Playlists.AddFilterRule(pbc, rule)
+		if err != nil {
+			s.Logger.Printf("error adding filter rule: %v\n", err)
+			RenderError(w, fmt.Sprintf("error adding filter rule: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusCreated, rule); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// AllowlistOnlyHandler turns the playback client's allowlist-only mode on or off.
+func (s *HTTPServer) AllowlistOnlyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		on, err := strconv.ParseBool(r.URL.Query().Get("allowlist_only"))
+		if err != nil {
+			RenderError(w, "invalid allowlist_only", http.StatusBadRequest)
+			return
+		}
+
+		st, err := s.Playlists.SetAllowlistOnly(pbc, on)
+		if err != nil {
+			s.Logger.Printf("error setting allowlist-only mode: %v\n", err)
+			RenderError(w, fmt.Sprintf("error setting allowlist-only mode: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, st); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// FilterDeleteHandler deletes one of the playback client's filter rules.
+func (s *HTTPServer) FilterDeleteHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		id, err := strconv.ParseInt(r.PathValue("ruleID"), 10, 64)
+		if err != nil || id < 1 {
+			RenderError(w, "invalid rule ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := s.Playlists.DeleteFilterRule(pbc, id); err != nil {
+			if errors.Is(err, ErrUnknownFilterRule) {
+				RenderError(w, err.Error(), http.StatusNotFound)
+				return
+			}
+
+			s.Logger.Printf("error deleting filter rule: %v\n", err)
+			RenderError(w, fmt.Sprintf("error deleting filter rule: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		http.Error(w, "", http.StatusNoContent)
+	})
+}
+
+// BlockedHandler returns a page of the videos the playback client's filter rules have blocked,
+// newest first.
+func (s *HTTPServer) BlockedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		var page, perPage int
+		if p := r.URL.Query().Get("page"); p != "" {
+			if page, err = strconv.Atoi(p); err != nil {
+				RenderError(w, "invalid page", http.StatusBadRequest)
+				return
+			}
+		}
+
+		if pp := r.URL.Query().Get("per_page"); pp != "" {
+			if perPage, err = strconv.Atoi(pp); err != nil {
+				RenderError(w, "invalid per_page", http.StatusBadRequest)
+				return
+			}
+		}
+
+		bp, err := NewBlockedPage(page, perPage)
+		if err != nil {
+			RenderError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		bp, err = s.DB.BlockedList(pbc.ID, bp)
+		if err != nil {
+			s.Logger.Printf("error listing blocked videos: %v\n", err)
+			RenderError(w, fmt.Sprintf("error listing blocked videos: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, bp); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// WindowsHandler returns the playback client's playback windows and whether it is inside one.
+func (s *HTTPServer) WindowsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, s.Playlists.Windows(pbc)); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// WindowAddHandler adds a playback window to the playback client.
+func (s *HTTPServer) WindowAddHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		q := r.URL.Query()
+		standby := false
+		if sb := q.Get("standby"); sb != "" {
+			if standby, err = strconv.ParseBool(sb); err != nil {
+				RenderError(w, "invalid standby", http.StatusBadRequest)
+				return
+			}
+		}
+
+		win, err := NewPlaybackWindow(pbc.ID, q.Get("days"), q.Get("start"), q.Get("end"), q.Get("tz"), standby)
+		if err != nil {
+			RenderError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		win, err = s.Playlists.AddWindow(pbc, win)
+		if err != nil {
+			s.Logger.Printf("error adding playback window: %v\n", err)
+			RenderError(w, fmt.Sprintf("error adding playback window: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusCreated, win); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// WindowDeleteHandler deletes one of the playback client's playback windows.
+func (s *HTTPServer) WindowDeleteHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		id, err := strconv.ParseInt(r.PathValue("windowID"), 10, 64)
+		if err != nil || id < 1 {
+			RenderError(w, "invalid window ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := s.Playlists.DeleteWindow(pbc, id); err != nil {
+			if errors.Is(err, ErrUnknownWindow) {
+				RenderError(w, err.Error(), http.StatusNotFound)
+				return
+			}
+
+			s.Logger.Printf("error deleting playback window: %v\n", err)
+			RenderError(w, fmt.Sprintf("error deleting playback window: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		http.Error(w, "", http.StatusNoContent)
+	})
+}
+
+// ImportHandler appends every video in a YouTube playlist to the end of the playback client's
+// playlist. Videos already queued are skipped and the outcome for each video is returned. Requests
+// without a url are handed to ImportFileHandler.
+func (s *HTTPServer) ImportHandler() http.Handler {
+	importFile := s.ImportFileHandler()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Without a YouTube playlist url the request body holds an exported playlist file.
+		if !r.URL.Query().Has("url") {
+			importFile.ServeHTTP(w, r)
+			return
+		}
+
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		listID, err := ParsePlaylistURL(r.URL.Query().Get("url"))
+		if err != nil {
+			RenderError(w, fmt.Sprintf("invalid url: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		vids, err := s.Fetcher.PlaylistVideos(r.Context(), listID)
+		if err != nil {
+			if errors.Is(err, ErrPlaylistNotFound) {
+				RenderError(w, "youtube playlist not found", http.StatusNotFound)
+				return
+			}
+
+			s.Logger.Printf("error fetching youtube playlist: %v\n", err)
+			RenderError(w, fmt.Sprintf("error fetching youtube playlist: %v", err), http.StatusBadGateway)
+			return
+		}
+
+		results, err := s.Playlists.ImportVideos(pbc, vids, RequestSubmitter(r))
+		if err != nil {
+			if status, ok := rejectStatus(err); ok {
+				RenderError(w, err.Error(), status)
+				return
+			}
+
+			s.Logger.Printf("error importing videos: %v\n", err)
+			RenderError(w, fmt.Sprintf("error importing videos: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		pl, _ := s.Playlists.Get(pbc)
+		msg := struct {
+			Message  string      `json:"message"`
+			Results  []AddResult `json:"results"`
+			Playlist Playlist    `json:"playlist"`
+		}{
+			Message:  fmt.Sprintf("%d of %d videos added to playlist", countAdded(results), len(results)),
+			Results:  results,
+			Playlist: pl,
+		}
+
+		if err := RenderJSON(w, http.StatusOK, msg); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ExportHandler returns a http.Handler that downloads the playlist for the provided playback client
+// ID as a json, m3u, or csv file.
+func (s *HTTPServer) ExportHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		format, err := ParseExportFormat(r.URL.Query().Get("format"))
+		if err != nil {
+			RenderError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		pl, ok := s.Playlists.Get(pbc)
+		if !ok {
+			RenderError(w, ErrNoActivePlaylist.Error(), http.StatusConflict)
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := ExportPlaylist(&buf, format, pl); err != nil {
+			s.Logger.Printf("error exporting playlist: %v\n", err)
+			RenderError(w, fmt.Sprintf("error exporting playlist: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set(
+			"Content-Disposition",
+			fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s.%s", pbc.Name, format)),
+		)
+
+		if _, err := buf.WriteTo(w); err != nil {
+			s.Logger.Printf("error writing export: %v\n", err)
+		}
+	})
+}
+
+// ImportFileHandler returns a http.Handler that reads a playlist file made by ExportHandler from
+// the request body and adds its videos to the playlist for the provided playback client ID. In
+// append mode the videos are added to the end of the playlist and in replace mode they replace it.
+// A result is returned for every entry in the file, including any rows which could not be read.
+func (s *HTTPServer) ImportFileHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		format, err := ParseExportFormat(r.URL.Query().Get("format"))
+		if err != nil {
+			RenderError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var replace bool
+		switch mode := r.URL.Query().Get("mode"); mode {
+		case "", "append":
+		case "replace":
+			replace = true
+		default:
+			RenderError(w, fmt.Sprintf("invalid mode: %q", mode), http.StatusBadRequest)
+			return
+		}
+
+		details, results, err := ParsePlaylistFile(http.MaxBytesReader(w, r.Body, maxBodySize), format)
+		if err != nil {
+			RenderError(w, fmt.Sprintf("invalid playlist file: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if len(details) > maxBatchVideos {
+			RenderError(w, fmt.Sprintf("too many videos: the limit is %d", maxBatchVideos), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		results, err = s.Playlists.ImportPlaylist(pbc, details, results, replace, RequestSubmitter(r))
+		if err != nil {
+			if errors.Is(err, ErrNoActivePlaylist) {
+				RenderError(w, err.Error(), http.StatusConflict)
+				return
+			}
+
+			if status, ok := rejectStatus(err); ok {
+				RenderError(w, err.Error(), status)
+				return
+			}
+
+			s.Logger.Printf("error importing playlist: %v\n", err)
+			RenderError(w, fmt.Sprintf("error importing playlist: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		pl, _ := s.Playlists.Get(pbc)
+		msg := struct {
+			Message  string      `json:"message"`
+			Results  []AddResult `json:"results"`
+			Playlist Playlist    `json:"playlist"`
+		}{
+			Message:  fmt.Sprintf("%d of %d videos added to playlist", countAdded(results), len(results)),
+			Results:  results,
+			Playlist: pl,
+		}
+
+		if err := RenderJSON(w, http.StatusOK, msg); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// BulkAddHandler returns a http.Handler that adds a JSON array of videos to the playlist for the
+// provided playback client ID in one write. Each entry is a video ID or URL, or an object with the
+// video and optional start_seconds, end_seconds, and loop. The videos are added as a block at the
+// position given in the query string, or at the end of the playlist. A result is returned for
+// every entry.
+func (s *HTTPServer) BulkAddHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pbc, err := s.GetPBC(w, r)
+		if err != nil {
+			return
+		}
+
+		position := -1
+		if raw := r.URL.Query().Get("position"); raw != "" {
+			if position, err = strconv.Atoi(raw); err != nil || position < 0 {
+				RenderError(w, "invalid position", http.StatusBadRequest)
+				return
+			}
+		}
+
+		var items []BulkItem
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&items); err != nil {
+			RenderError(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if len(items) == 0 {
+			RenderError(w, "no videos to add", http.StatusBadRequest)
+			return
+		}
+
+		if len(items) > maxBatchVideos {
+			RenderError(w, fmt.Sprintf("too many videos: the limit is %d", maxBatchVideos), http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		results, err := s.Playlists.AddBatch(pbc, items, position, RequestSubmitter(r))
+		if err != nil {
+			if errors.Is(err, ErrNoActivePlaylist) {
+				RenderError(w, err.Error(), http.StatusConflict)
+				return
+			}
+
+			if status, ok := rejectStatus(err); ok {
+				RenderError(w, err.Error(), status)
+				return
+			}
+
+			s.Logger.Printf("error adding videos to playlist: %v\n", err)
+			RenderError(w, fmt.Sprintf("error adding videos to playlist: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		pl, _ := s.Playlists.Get(pbc)
+		msg := struct {
+			Message  string      `json:"message"`
+			Results  []AddResult `json:"results"`
+			Playlist Playlist    `json:"playlist"`
+		}{
+			Message:  fmt.Sprintf("%d of %d videos added to playlist", countAdded(results), len(results)),
+			Results:  results,
+			Playlist: pl,
+		}
+
+		if err := RenderJSON(w, http.StatusOK, msg); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// rejectStatus returns the HTTP status for an error from a video being refused by one of the queue
+// limits or filter rules. The bool is false if err is neither.
+func rejectStatus(err error) (int, bool) {
+	switch {
+	case errors.Is(err, ErrVideoBlocked):
+		return http.StatusForbidden, true
+	case errors.Is(err, ErrQueueFull), errors.Is(err, ErrQueueDuration):
+		return http.StatusConflict, true
+	case errors.Is(err, ErrSubmitterLimit):
+		return http.StatusTooManyRequests, true
+	}
+
+	return 0, false
+}
+
+// countAdded returns how many results were added or moved into place.
+func countAdded(results []AddResult) int {
+	n := 0
+	for _, res := range results {
+		if res.Status == ResultAdded || res.Status == ResultMoved {
+			n++
+		}
+	}
+
+	return n
+}
+
+// ############################################################################################## //
+// ###################################    Schedule Handlers    ################################## //
+// ############################################################################################## //
+
+// GetScheduleID returns the schedule ID from the request path. If the ID is missing or invalid a
+// 400 Bad Request response is sent and an error is returned.
+func (s *HTTPServer) GetScheduleID(w http.ResponseWriter, r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(r.PathValue("scheduleID"), 10, 64)
+	if err != nil || id < 1 {
+		RenderError(w, "invalid schedule ID", http.StatusBadRequest)
+		return 0, fmt.Errorf("invalid schedule ID: %q", r.PathValue("scheduleID"))
+	}
+
+	return id, nil
+}
+
+// readScheduleRequest decodes a ScheduleRequest from the request body and builds the schedule. If
+// the body or the schedule is invalid a 400 Bad Request response is sent and an error is returned.
+func readScheduleRequest(w http.ResponseWriter, r *http.Request) (Schedule, error) {
+	var req ScheduleRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req); err != nil {
+		RenderError(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return Schedule{}, err
+	}
+
+	sc, err := req.Schedule(time.Now())
+	if err != nil {
+		RenderError(w, fmt.Sprintf("invalid schedule: %v", err), http.StatusBadRequest)
+		return sc, err
+	}
+
+	return sc, nil
+}
+
+// ScheduleListHandler returns a http.Handler that lists every schedule, or only those for the
+// playback client ID in the query string.
+func (s *HTTPServer) ScheduleListHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		list := s.Schedules.List(r.URL.Query().Get("pbc"))
+		if err := RenderJSON(w, http.StatusOK, list); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ScheduleCreateHandler returns a http.Handler that creates a schedule from the JSON request body.
+func (s *HTTPServer) ScheduleCreateHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sc, err := readScheduleRequest(w, r)
+		if err != nil {
+			return
+		}
+
+		sc, err = s.Schedules.Create(sc)
+		if err != nil {
+			s.Logger.Printf("error creating schedule: %v\n", err)
+			RenderError(w, fmt.Sprintf("error creating schedule: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusCreated, sc); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ScheduleGetHandler returns a http.Handler that returns the schedule with the ID in the path.
+func (s *HTTPServer) ScheduleGetHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := s.GetScheduleID(w, r)
+		if err != nil {
+			return
+		}
+
+		sc, ok := s.Schedules.Get(id)
+		if !ok {
+			RenderError(w, "schedule not found", http.StatusNotFound)
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, sc); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ScheduleUpdateHandler returns a http.Handler that replaces a schedule's settings with the JSON
+// request body. The next run is worked out again from the new settings.
+func (s *HTTPServer) ScheduleUpdateHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := s.GetScheduleID(w, r)
+		if err != nil {
+			return
+		}
+
+		sc, err := readScheduleRequest(w, r)
+		if err != nil {
+			return
+		}
+
+		sc, err = s.Schedules.Update(id, sc)
+		if err != nil {
+			if errors.Is(err, ErrUnknownSchedule) {
+				RenderError(w, "schedule not found", http.StatusNotFound)
+				return
+			}
+
+			s.Logger.Printf("error updating schedule: %v\n", err)
+			RenderError(w, fmt.Sprintf("error updating schedule: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, sc); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ScheduleDeleteHandler returns a http.Handler that deletes the schedule with the ID in the path.
+func (s *HTTPServer) ScheduleDeleteHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := s.GetScheduleID(w, r)
+		if err != nil {
+			return
+		}
+
+		if err := s.Schedules.Delete(id); err != nil {
+			if errors.Is(err, ErrUnknownSchedule) {
+				RenderError(w, "schedule not found", http.StatusNotFound)
+				return
+			}
+
+			s.Logger.Printf("error deleting schedule: %v\n", err)
+			RenderError(w, fmt.Sprintf("error deleting schedule: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		http.Error(w, "", http.StatusNoContent)
+	})
+}
+
+// ############################################################################################## //
+// ###################################    Metadata Handlers    ################################## //
+// ############################################################################################## //
+
+// MetadataGetHandler returns the video's cached metadata and whether it has expired.
+func (s *HTTPServer) MetadataGetHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vid := r.PathValue("video_id")
+		if err := validateVideoID(vid); err != nil {
+			RenderError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		m, err := s.DB.MetadataGet(vid)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				RenderError(w, "metadata not cached", http.StatusNotFound)
+				return
+			}
+
+			s.Logger.Printf("error getting metadata: %v\n", err)
+			RenderError(w, fmt.Sprintf("error getting metadata: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		msg := struct {
+			VideoMetadata
+			Expired bool `json:"expired"`
+		}{
+			VideoMetadata: m,
+			Expired:       time.Since(m.FetchedAt) >= s.Metadata.TTL,
+		}
+
+		if err := RenderJSON(w, http.StatusOK, msg); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// MetadataRefreshHandler looks the video up again, updates the cache, and applies the new metadata
+// to any playlists holding the video.
+func (s *HTTPServer) MetadataRefreshHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vid := r.PathValue("video_id")
+		if err := validateVideoID(vid); err != nil {
+			RenderError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		m, err := s.Metadata.Refresh(r.Context(), vid)
+		if err != nil {
+			if errors.Is(err, ErrMetadataNotFound) {
+				RenderError(w, "video not found", http.StatusNotFound)
+				return
+			}
+
+			s.Logger.Printf("error refreshing metadata: %v\n", err)
+			RenderError(w, fmt.Sprintf("error refreshing metadata: %v", err), http.StatusBadGateway)
+			return
+		}
+
+		if err := s.Playlists.UpdateMetadata(m); err != nil {
+			s.Logger.Printf("error updating playlists: %v\n", err)
+			RenderError(w, fmt.Sprintf("error updating playlists: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		if err := RenderJSON(w, http.StatusOK, m); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
+	})
+}
+
+// MetadataDeleteHandler removes the video's metadata from the cache.
+func (s *HTTPServer) MetadataDeleteHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vid := r.PathValue("video_id")
+		if err := validateVideoID(vid); err != nil {
+			RenderError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := s.Metadata.Purge(vid); err != nil {
+			s.Logger.Printf("error deleting metadata: %v\n", err)
+			RenderError(w, fmt.Sprintf("error deleting metadata: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// MetadataPurgeHandler empties the metadata cache, or only removes the expired entries if expired
+// is true.
+func (s *HTTPServer) MetadataPurgeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n int64
+		var err error
+		if expired, _ := strconv.ParseBool(r.URL.Query().Get("expired")); expired {
+			n, err = s.Metadata.PurgeExpired()
+		} else {
+			n, err = s.Metadata.PurgeAll()
+		}
+
+		if err != nil {
+			s.Logger.Printf("error purging metadata: %v\n", err)
+			RenderError(w, fmt.Sprintf("error purging metadata: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		msg := struct {
+			Message string `json:"message"`
+			Purged  int64  `json:"purged"`
+		}{
+			Message: "metadata purged",
+			Purged:  n,
+		}
+
+		if err := RenderJSON(w, http.StatusOK, msg); err != nil {
+			s.Logger.Printf("error rendering json: %v\n", err)
+			RenderError(w, fmt.Sprintf("error rendering json: %v", err), http.StatusInternalServerError)
+		}
 	})
 }
 
@@ -0,0 +1,541 @@
+package application
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxScheduleNameLength = 64
+
+	// scheduleGrace is how late a run may start before it counts as missed.
+	scheduleGrace = time.Minute
+	// maxScheduleWait caps how long the scheduler sleeps between checks so a jump in the system
+	// clock, or a suspend, is noticed within a minute.
+	maxScheduleWait = time.Minute
+)
+
+// ScheduleAction is what a schedule does when it runs.
+type ScheduleAction string
+
+const (
+	// ScheduleAdd adds the video to the end of the playback client's playlist.
+	ScheduleAdd ScheduleAction = "add"
+	// SchedulePlayNext adds the video to the front of the playback client's playlist.
+	SchedulePlayNext ScheduleAction = "play-next"
+	// SchedulePlaylist switches the playback client to the playlist.
+	SchedulePlaylist ScheduleAction = "playlist"
+)
+
+// MissedPolicy decides what happens to runs which were missed because ytqueuer was not running.
+type MissedPolicy string
+
+const (
+	// MissedSkip drops missed runs and waits for the next one.
+	MissedSkip MissedPolicy = "skip"
+	// MissedRunOnce runs the schedule once as soon as possible, however many runs were missed.
+	MissedRunOnce MissedPolicy = "run-once"
+)
+
+var ErrUnknownSchedule = fmt.Errorf("schedule not found")
+
+// atLayouts are the formats accepted for a one-shot schedule's time. Times without an offset are
+// in the schedule's time zone.
+var atLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// Schedule runs an action against a playback client, either on a cron expression or once at a set
+// time. Cron expressions are read in the schedule's time zone.
+type Schedule struct {
+	ID           int64          `json:"id"`
+	Name         string         `json:"name"`
+	PBCID        string         `json:"pbc_id"`
+	Cron         string         `json:"cron,omitempty"`
+	At           *time.Time     `json:"at,omitempty"`
+	TimeZone     string         `json:"time_zone"`
+	Action       ScheduleAction `json:"action"`
+	VideoID      string         `json:"video_id,omitempty"`
+	StartSeconds int            `json:"start_seconds,omitempty"`
+	EndSeconds   int            `json:"end_seconds,omitempty"`
+	Loop         bool           `json:"loop,omitempty"`
+	PlaylistID   string         `json:"playlist_id,omitempty"`
+	Missed       MissedPolicy   `json:"missed"`
+	Enabled      bool           `json:"enabled"`
+	NextRun      *time.Time     `json:"next_run,omitempty"`
+	LastRun      *time.Time     `json:"last_run,omitempty"`
+	LastError    string         `json:"last_error,omitempty"`
+	CreatedAt    time.Time      `json:"created_at"`
+
+	cron CronSpec
+	loc  *time.Location
+}
+
+// ScheduleRequest is the body of a request to create or update a schedule. Exactly one of Cron and
+// At must be set. At is an RFC 3339 time, a date and time in the schedule's time zone such as
+// 2024-06-01T18:30, or a time of day such as 18:30 for the next time the clock reaches it. Video may
+// be a video ID or any URL ParseVideoURL accepts and is only used by the add and play-next actions.
+type ScheduleRequest struct {
+	Name         string         `json:"name"`
+	PBCID        string         `json:"pbc_id"`
+	Cron         string         `json:"cron"`
+	At           string         `json:"at"`
+	TimeZone     string         `json:"time_zone"`
+	Action       ScheduleAction `json:"action"`
+	Video        string         `json:"video"`
+	StartSeconds int            `json:"start_seconds"`
+	EndSeconds   int            `json:"end_seconds"`
+	Loop         bool           `json:"loop"`
+	PlaylistID   string         `json:"playlist_id"`
+	Missed       MissedPolicy   `json:"missed"`
+	Enabled      *bool          `json:"enabled"`
+}
+
+// Schedule validates the request and builds the schedule it describes. The time zone defaults to
+// the server's, the missed policy to MissedSkip, and new schedules are enabled unless Enabled is
+// false. The playback client and playlist are not checked.
+func (req ScheduleRequest) Schedule(now time.Time) (Schedule, error) {
+	sc := Schedule{
+		Name:       strings.TrimSpace(req.Name),
+		PBCID:      req.PBCID,
+		Cron:       strings.Join(strings.Fields(req.Cron), " "),
+		TimeZone:   req.TimeZone,
+		Action:     req.Action,
+		PlaylistID: req.PlaylistID,
+		Missed:     req.Missed,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+	}
+
+	if utf8.RuneCountInString(sc.Name) > maxScheduleNameLength {
+		return sc, fmt.Errorf("schedule name is longer than %d characters", maxScheduleNameLength)
+	}
+
+	if sc.PBCID == "" {
+		return sc, fmt.Errorf("pbc_id is empty")
+	}
+
+	if sc.TimeZone == "" {
+		sc.TimeZone = "Local"
+	}
+
+	if sc.Missed == "" {
+		sc.Missed = MissedSkip
+	}
+
+	switch sc.Missed {
+	case MissedSkip, MissedRunOnce:
+	default:
+		return sc, fmt.Errorf("invalid missed policy: %q", sc.Missed)
+	}
+
+	switch sc.Action {
+	case ScheduleAdd, SchedulePlayNext:
+		v, err := BulkItem{
+			Video:        req.Video,
+			StartSeconds: req.StartSeconds,
+			EndSeconds:   req.EndSeconds,
+			Loop:         req.Loop,
+		}.VideoURL()
+		if err != nil {
+			return sc, err
+		}
+
+		sc.VideoID = v.VideoID
+		sc.StartSeconds = v.StartSeconds
+		sc.EndSeconds = v.EndSeconds
+		sc.Loop = v.Loop
+		sc.PlaylistID = ""
+	case SchedulePlaylist:
+		if sc.PlaylistID == "" {
+			return sc, fmt.Errorf("playlist_id is empty")
+		}
+	default:
+		return sc, fmt.Errorf("invalid action: %q", sc.Action)
+	}
+
+	if err := sc.prepare(); err != nil {
+		return sc, err
+	}
+
+	switch {
+	case sc.Cron != "" && req.At != "":
+		return sc, fmt.Errorf("only one of cron and at may be set")
+	case sc.Cron == "" && req.At == "":
+		return sc, fmt.Errorf("one of cron or at must be set")
+	case req.At != "":
+		at, err := parseAt(req.At, now.In(sc.loc))
+		if err != nil {
+			return sc, err
+		}
+
+		if !at.After(now) {
+			return sc, fmt.Errorf("at is in the past: %s", req.At)
+		}
+
+		sc.At = &at
+	}
+
+	if sc.next(now).IsZero() {
+		return sc, fmt.Errorf("schedule never runs")
+	}
+
+	return sc, nil
+}
+
+// parseAt reads a one-shot time. A time of day on its own is the next time the clock reaches it
+// after now.
+func parseAt(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range atLayouts {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	if t, err := time.Parse("15:04", s); err == nil {
+		at := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+		if !at.After(now) {
+			at = at.AddDate(0, 0, 1)
+		}
+
+		return at, nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid at: %q", s)
+}
+
+// prepare loads the schedule's time zone and parses its cron expression.
+func (sc *Schedule) prepare() error {
+	loc, err := time.LoadLocation(sc.TimeZone)
+	if err != nil {
+		return fmt.Errorf("invalid time zone: %q", sc.TimeZone)
+	}
+
+	sc.loc = loc
+	if sc.Cron != "" {
+		if sc.cron, err = ParseCron(sc.Cron); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// next returns the first time after t the schedule should run, or the zero time if it never runs
+// again.
+func (sc Schedule) next(t time.Time) time.Time {
+	if sc.Cron != "" {
+		return sc.cron.Next(t.In(sc.loc))
+	}
+
+	if sc.At != nil && sc.At.After(t) {
+		return sc.At.In(sc.loc)
+	}
+
+	return time.Time{}
+}
+
+// Scheduler runs schedules at their set times. Schedules are kept in memory and written through to
+// the database.
+type Scheduler struct {
+	Logger *log.Logger
+
+	db        *SqliteDB
+	pls       *Playlists
+	mu        sync.Mutex
+	schedules map[int64]Schedule
+	wake      chan struct{}
+}
+
+// NewScheduler creates a new, empty Scheduler which queues videos through the playlist store.
+func NewScheduler(logger *log.Logger, db *SqliteDB, pls *Playlists) *Scheduler {
+	return &Scheduler{
+		Logger:    logger,
+		db:        db,
+		pls:       pls,
+		schedules: make(map[int64]Schedule),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// LoadFromDB loads every schedule from the database. Runs which were missed while ytqueuer was not
+// running are handled by each schedule's missed policy once Run starts.
+func (s *Scheduler) LoadFromDB() error {
+	list, err := s.db.ScheduleList()
+	if err != nil {
+		return fmt.Errorf("Scheduler.LoadFromDB: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, sc := range list {
+		if err := sc.prepare(); err != nil {
+			s.Logger.Printf("Scheduler: schedule %d: %v\n", sc.ID, err)
+			sc.Enabled = false
+		}
+
+		if sc.Enabled && sc.NextRun == nil {
+			if next := sc.next(now); !next.IsZero() {
+				sc.NextRun = &next
+			}
+		}
+
+		s.schedules[sc.ID] = sc
+	}
+
+	return nil
+}
+
+// List returns the schedules for the playback client, or every schedule if pbcID is empty, in the
+// order they were created.
+func (s *Scheduler) List(pbcID string) []Schedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]Schedule, 0, len(s.schedules))
+	for _, sc := range s.schedules {
+		if pbcID == "" || sc.PBCID == pbcID {
+			list = append(list, sc)
+		}
+	}
+
+	slices.SortFunc(list, func(a, b Schedule) int { return cmp.Compare(a.ID, b.ID) })
+	return list
+}
+
+// Get returns the schedule. The bool is false if the schedule does not exist.
+func (s *Scheduler) Get(id int64) (Schedule, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sc, ok := s.schedules[id]
+	return sc, ok
+}
+
+// Create saves a new schedule built by ScheduleRequest.Schedule.
+func (s *Scheduler) Create(sc Schedule) (Schedule, error) {
+	if err := s.check(sc); err != nil {
+		return sc, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sc.CreatedAt = time.Now()
+	sc.setNextRun(sc.CreatedAt)
+	id, err := s.db.ScheduleCreate(sc)
+	if err != nil {
+		return sc, fmt.Errorf("Scheduler.Create: %w", err)
+	}
+
+	sc.ID = id
+	s.schedules[id] = sc
+	s.notify()
+	return sc, nil
+}
+
+// Update replaces the schedule's settings with those of sc. The time of the last run is kept.
+func (s *Scheduler) Update(id int64, sc Schedule) (Schedule, error) {
+	if err := s.check(sc); err != nil {
+		return sc, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.schedules[id]
+	if !ok {
+		return sc, ErrUnknownSchedule
+	}
+
+	sc.ID = id
+	sc.CreatedAt = old.CreatedAt
+	sc.LastRun = old.LastRun
+	sc.LastError = old.LastError
+	sc.setNextRun(time.Now())
+	if err := s.db.ScheduleUpdate(sc); err != nil {
+		return sc, fmt.Errorf("Scheduler.Update: %w", err)
+	}
+
+	s.schedules[id] = sc
+	s.notify()
+	return sc, nil
+}
+
+// Delete removes the schedule.
+func (s *Scheduler) Delete(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.schedules[id]; !ok {
+		return ErrUnknownSchedule
+	}
+
+	if err := s.db.ScheduleDelete(id); err != nil {
+		return fmt.Errorf("Scheduler.Delete: %w", err)
+	}
+
+	delete(s.schedules, id)
+	return nil
+}
+
+// check makes sure the schedule's playback client, and playlist if it has one, exist.
+func (s *Scheduler) check(sc Schedule) error {
+	if _, err := s.db.PlaybackClientGet(sc.PBCID); err != nil {
+		return fmt.Errorf("pbc_id not found: %s", sc.PBCID)
+	}
+
+	if sc.Action == SchedulePlaylist {
+		if _, _, ok := s.pls.GetPlaylist(sc.PlaylistID); !ok {
+			return ErrUnknownPlaylist
+		}
+	}
+
+	return nil
+}
+
+// setNextRun sets when the schedule runs next after t. A schedule which will never run again is
+// disabled.
+func (sc *Schedule) setNextRun(t time.Time) {
+	sc.NextRun = nil
+	if !sc.Enabled {
+		return
+	}
+
+	next := sc.next(t)
+	if next.IsZero() {
+		sc.Enabled = false
+		return
+	}
+
+	sc.NextRun = &next
+}
+
+// notify wakes Run so it picks up a changed schedule.
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run runs each schedule when it is due until ctx is done. A run which starts more than a minute
+// late, such as one that came due while ytqueuer was stopped, is handled by the schedule's missed
+// policy.
+func (s *Scheduler) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+
+		wait := s.runDue(time.Now())
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+
+		timer.Reset(wait)
+	}
+}
+
+// runDue runs every schedule which is due at now and returns how long to wait for the next one.
+func (s *Scheduler) runDue(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	wait := maxScheduleWait
+	for id, sc := range s.schedules {
+		if !sc.Enabled || sc.NextRun == nil {
+			continue
+		}
+
+		if sc.NextRun.After(now) {
+			wait = min(wait, sc.NextRun.Sub(now))
+			continue
+		}
+
+		if now.Sub(*sc.NextRun) > scheduleGrace && sc.Missed == MissedSkip {
+			s.Logger.Printf("Scheduler: schedule %d: skipping run missed at %s\n", id, sc.NextRun.Format(time.RFC3339))
+		} else {
+			sc.LastError = ""
+			if err := s.run(sc); err != nil {
+				s.Logger.Printf("Scheduler: schedule %d: %v\n", id, err)
+				sc.LastError = err.Error()
+			}
+
+			ran := now
+			sc.LastRun = &ran
+		}
+
+		sc.setNextRun(now)
+		if err := s.db.ScheduleUpdate(sc); err != nil {
+			s.Logger.Printf("Scheduler: schedule %d: %v\n", id, err)
+		}
+
+		s.schedules[id] = sc
+		if sc.NextRun != nil {
+			wait = min(wait, sc.NextRun.Sub(now))
+		}
+	}
+
+	return wait
+}
+
+// run performs the schedule's action. Videos are queued the same way as the add and play next
+// routes.
+func (s *Scheduler) run(sc Schedule) error {
+	pbc, err := s.db.PlaybackClientGet(sc.PBCID)
+	if err != nil {
+		return fmt.Errorf("Scheduler.run: %w", err)
+	}
+
+	v := VideoURL{
+		VideoID:      sc.VideoID,
+		StartSeconds: sc.StartSeconds,
+		EndSeconds:   sc.EndSeconds,
+		Loop:         sc.Loop,
+	}
+
+	by := Submitter{Name: "schedule: " + sc.Name}
+	if sc.Name == "" {
+		by.Name = fmt.Sprintf("schedule: %d", sc.ID)
+	}
+
+	switch sc.Action {
+	case ScheduleAdd:
+		_, err = s.pls.Add(pbc, v, by)
+	case SchedulePlayNext:
+		_, err = s.pls.PlayNext(pbc, v, by)
+	case SchedulePlaylist:
+		_, err = s.pls.SetActivePlaylist(pbc, sc.PlaylistID)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Scheduler.run: %w", err)
+	}
+
+	return nil
+}
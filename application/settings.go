@@ -0,0 +1,52 @@
+package application
+
+import (
+	"fmt"
+)
+
+// PlaybackMode controls which video a playback client plays next and what happens to a video once
+// it has played through.
+type PlaybackMode string
+
+const (
+	// ModeNormal plays the queue in order and drops each video once it has played.
+	ModeNormal PlaybackMode = "normal"
+	// ModeShuffle plays a random video from the queue each time and drops it once it has played.
+	ModeShuffle PlaybackMode = "shuffle"
+	// ModeRepeatOne keeps playing the first video in the queue until it is removed.
+	ModeRepeatOne PlaybackMode = "repeat-one"
+	// ModeRepeatAll plays the queue in order and moves each video to the end once it has played.
+	ModeRepeatAll PlaybackMode = "repeat-all"
+	// ModeFair plays the queue in order like ModeNormal but places each added video so the people
+	// adding videos take turns.
+	ModeFair PlaybackMode = "fair"
+	// ModeVoteOrdered plays the queue in order but keeps every video after the one playing sorted
+	// by score, with ties going to the video added first.
+	ModeVoteOrdered PlaybackMode = "vote-ordered"
+)
+
+// ParsePlaybackMode returns the PlaybackMode named by s.
+func ParsePlaybackMode(s string) (PlaybackMode, error) {
+	switch m := PlaybackMode(s); m {
+	case ModeNormal, ModeShuffle, ModeRepeatOne, ModeRepeatAll, ModeFair, ModeVoteOrdered:
+		return m, nil
+	}
+
+	return "", fmt.Errorf("invalid playback mode: %q", s)
+}
+
+// PBCSettings holds the per playback client settings which control how its queue is played.
+// FallbackPlaylistID is only used when FallbackSource is FallbackPlaylist.
+type PBCSettings struct {
+	PBCID string       `json:"pbc_id"`
+	Mode  PlaybackMode `json:"mode"`
+	QueueLimits
+	AllowlistOnly      bool           `json:"allowlist_only"`
+	FallbackSource     FallbackSource `json:"fallback_source"`
+	FallbackPlaylistID string         `json:"fallback_playlist_id"`
+}
+
+// NewPBCSettings returns the default settings for a playback client.
+func NewPBCSettings(pbcID string) PBCSettings {
+	return PBCSettings{PBCID: pbcID, Mode: ModeNormal, FallbackSource: FallbackNone}
+}
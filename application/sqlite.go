@@ -3,11 +3,12 @@ package application
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
+	"time"
 
 	// libray has to be imported to register the driver.
 
@@ -15,10 +16,22 @@ import (
 )
 
 const (
-	db_folder    = "db"
-	tb_playlists = "playlists"
-	tb_wol       = "wol"
-	tb_cec       = "cec"
+	db_folder         = "db"
+	tb_pbcs           = "pbcs"
+	tb_playlists      = "playlists"
+	tb_playlist_items = "playlist_items"
+	tb_wol            = "wol"
+	tb_cec            = "cec"
+	tb_history        = "history"
+	tb_video_metadata = "video_metadata"
+	tb_pbc_settings   = "pbc_settings"
+	tb_trash          = "trash"
+	tb_schedules      = "schedules"
+	tb_votes          = "votes"
+	tb_trash_votes    = "trash_votes"
+	tb_filter_rules   = "filter_rules"
+	tb_blocked_videos = "blocked_videos"
+	tb_windows        = "playback_windows"
 )
 
 var (
@@ -88,23 +101,6 @@ func (db *SqliteDB) Open() error {
 	return nil
 }
 
-// CuttleMigrate runs the migrations for each table in the main cuttle database.
-func (db *SqliteDB) Migrate() error {
-	if err := db.PlaylistsMigrate(); err != nil {
-		return fmt.Errorf("SqliteDB.Migrate: failed to migrate %s: %w", tb_playlists, err)
-	}
-
-	if err := db.CECMigrate(); err != nil {
-		return fmt.Errorf("SqliteDB.Migrate: failed to migrate %s: %w", tb_cec, err)
-	}
-
-	if err := db.WOLMigrate(); err != nil {
-		return fmt.Errorf("SqliteDB.Migrate: failed to migrate %s: %w", tb_wol, err)
-	}
-
-	return nil
-}
-
 // IsUnique returns nil if no records exist in the table that match the where clause. If a record
 // exists, it returns an ErrRecordExists error. The 'where' clause should not include the "WHERE"
 // keyword but may include multiple column queries which are comma separated: 'col1 = ?, col2 = ?'.
@@ -179,26 +175,131 @@ func (db *SqliteDB) Exec(query string, args ...any) (sql.Result, error) {
 }
 
 // ############################################################################################## //
-// ####################################       Playlists      #################################### //
+// ##################################    Playback Clients    #################################### //
 // ############################################################################################## //
 
-// PlaylistsMigrate creates the 'playlists' table if it does not exist.
-func (db *SqliteDB) PlaylistsMigrate() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS ` + tb_playlists + ` (
-		id VARCHAR(11) NOT NULL PRIMARY KEY,
-		name VARCHAR(32) NOT NULL,
-		playlist TEXT NOT NULL DEFAULT '[]'
-	);
-	CREATE INDEX IF NOT EXISTS idx_playlists_id ON ` + tb_playlists + ` (id);`
+const pbcColumns = `id, name, COALESCE(playlist_id, '')`
+
+func scanPlaybackClient(row interface{ Scan(...any) error }) (PlaybackClient, error) {
+	pbc := PlaybackClient{}
+	err := row.Scan(
+		&pbc.ID,
+		&pbc.Name,
+		&pbc.PlaylistID,
+	)
+
+	return pbc, err
+}
+
+// PlaybackClientCreate creates a new playback client. The playback client's active playlist must
+// already exist.
+func (db *SqliteDB) PlaybackClientCreate(pbc PlaybackClient) error {
+	if pbc.ID == "" {
+		return fmt.Errorf("SqliteDB.PlaybackClientCreate: PlaybackClient.ID - %w", ErrParamEmpty)
+	}
+
+	if pbc.Name == "" {
+		return fmt.Errorf("SqliteDB.PlaybackClientCreate: PlaybackClient.Name - %w", ErrParamEmpty)
+	}
+
+	query := `INSERT INTO ` + tb_pbcs + ` (id, name, playlist_id) VALUES (?, ?, NULLIF(?, ''))`
+	if _, err := db.Exec(query, pbc.ID, pbc.Name, pbc.PlaylistID); err != nil {
+		if IsErrNotUnique(err) {
+			return fmt.Errorf("SqliteDB.PlaybackClientCreate: %w", ErrRecordExists)
+		}
+
+		return fmt.Errorf("SqliteDB.PlaybackClientCreate: %w", err)
+	}
+
+	return nil
+}
+
+// PlaybackClientGet retrieves a playback client from the database by ID.
+func (db *SqliteDB) PlaybackClientGet(id string) (PlaybackClient, error) {
+	query := `SELECT ` + pbcColumns + ` FROM ` + tb_pbcs + ` WHERE id = ?`
+	row, err := db.QueryRow(query, id)
+	if err != nil {
+		return PlaybackClient{}, fmt.Errorf("SqliteDB.PlaybackClientGet: %w", err)
+	}
+
+	pbc, err := scanPlaybackClient(row)
+	if err != nil {
+		return pbc, fmt.Errorf("SqliteDB.PlaybackClientGet: %w", err)
+	}
+
+	return pbc, nil
+}
+
+// PlaybackClientGetByName retrieves a playback client from the database by Name.
+func (db *SqliteDB) PlaybackClientGetByName(name string) (PlaybackClient, error) {
+	query := `SELECT ` + pbcColumns + ` FROM ` + tb_pbcs + ` WHERE name = ?`
+	row, err := db.QueryRow(query, name)
+	if err != nil {
+		return PlaybackClient{}, fmt.Errorf("SqliteDB.PlaybackClientGetByName: %w", err)
+	}
+
+	pbc, err := scanPlaybackClient(row)
+	if err != nil {
+		return pbc, fmt.Errorf("SqliteDB.PlaybackClientGetByName: %w", err)
+	}
+
+	return pbc, nil
+}
+
+// PlaybackClientList retrieves a list of all playback clients from the database.
+func (db *SqliteDB) PlaybackClientList() ([]PlaybackClient, error) {
+	query := `SELECT ` + pbcColumns + ` FROM ` + tb_pbcs
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("SqliteDB.PlaybackClientList: %w", err)
+	}
+	defer rows.Close()
+
+	var pbcs []PlaybackClient
+	for rows.Next() {
+		pbc, err := scanPlaybackClient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("SqliteDB.PlaybackClientList: %w", err)
+		}
+
+		pbcs = append(pbcs, pbc)
+	}
+
+	return pbcs, rows.Err()
+}
+
+// PlaybackClientSetPlaylist sets the playback client's active playlist.
+func (db *SqliteDB) PlaybackClientSetPlaylist(pbcID string, playlistID string) error {
+	if pbcID == "" {
+		return fmt.Errorf("SqliteDB.PlaybackClientSetPlaylist: pbcID - %w", ErrParamEmpty)
+	}
+
+	query := `UPDATE ` + tb_pbcs + ` SET playlist_id = NULLIF(?, '') WHERE id = ?`
+	if _, err := db.Exec(query, playlistID, pbcID); err != nil {
+		return fmt.Errorf("SqliteDB.PlaybackClientSetPlaylist: %w", err)
+	}
+
+	return nil
+}
 
-	if _, err := db.Exec(query); err != nil {
-		return fmt.Errorf("SqliteDB.PlaylistsMigrate: %w", err)
+// PlaybackClientDelete deletes a playback client from the database by ID. Its playlists are kept.
+func (db *SqliteDB) PlaybackClientDelete(id string) error {
+	if id == "" {
+		return fmt.Errorf("SqliteDB.PlaybackClientDelete: %w", ErrInvalidID)
+	}
+
+	query := `DELETE FROM ` + tb_pbcs + ` WHERE id = ?`
+	if _, err := db.Exec(query, id); err != nil {
+		return fmt.Errorf("SqliteDB.PlaybackClientDelete: %w", err)
 	}
 
 	return nil
 }
 
+// ############################################################################################## //
+// ####################################       Playlists      #################################### //
+// ############################################################################################## //
+
 // PlaylistIsUnique checks if the playlist id is unique in the database. If the id is not unique, it
 // returns an ErrRecordExists error.
 func (db *SqliteDB) PlaylistIsUnique(id string) error {
@@ -214,25 +315,22 @@ func (db *SqliteDB) PlaylistIsUnique(id string) error {
 	return err
 }
 
-// PlaylistCreate creates a new user in the database and returns the new user data. A password should
-// never be provided in plain text. PlaylistCreate will check for hash formatting.
-func (db *SqliteDB) PlaylistCreate(pbc PlaybackClient, playlist Playlist) error {
-	if pbc.ID == "" {
-		return fmt.Errorf("SqliteDB.PlaylistCreate: PlaybackClient.ID - %w", ErrParamEmpty)
+// PlaylistCreate creates a new, empty playlist.
+func (db *SqliteDB) PlaylistCreate(info PlaylistInfo) error {
+	if info.ID == "" {
+		return fmt.Errorf("SqliteDB.PlaylistCreate: PlaylistInfo.ID - %w", ErrParamEmpty)
 	}
 
-	if pbc.Name == "" {
-		return fmt.Errorf("SqliteDB.PlaylistCreate: PlaybackClient.Name - %w", ErrParamEmpty)
+	if info.Name == "" {
+		return fmt.Errorf("SqliteDB.PlaylistCreate: PlaylistInfo.Name - %w", ErrParamEmpty)
 	}
 
-	plData, err := json.Marshal(playlist)
-	if err != nil {
-		return fmt.Errorf("SqliteDB.PlaylistCreate: %w", err)
+	if info.Duplicates == "" {
+		info.Duplicates = DuplicatesReject
 	}
 
-	query := `INSERT INTO ` + tb_playlists + ` (id, name, playlist) VALUES (?, ?, ?)`
-	r, err := db.Exec(query, pbc.ID, pbc.Name, plData)
-	if err != nil {
+	query := `INSERT INTO ` + tb_playlists + ` (id, name, created_at, duplicates) VALUES (?, ?, ?, ?)`
+	if _, err := db.Exec(query, info.ID, info.Name, info.CreatedAt, info.Duplicates); err != nil {
 		if IsErrNotUnique(err) {
 			return fmt.Errorf("SqliteDB.PlaylistCreate: %w", ErrRecordExists)
 		}
@@ -240,193 +338,356 @@ func (db *SqliteDB) PlaylistCreate(pbc PlaybackClient, playlist Playlist) error
 		return fmt.Errorf("SqliteDB.PlaylistCreate: %w", err)
 	}
 
-	_, err = r.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("SqliteDB.PlaylistCreate: %w", err)
-	}
-
 	return nil
 }
 
-// PlaylistGet retrieves a playlist from the database by ID.
-func (db *SqliteDB) PlaylistGet(id string) (PlaybackClient, Playlist, error) {
-	query := `SELECT * FROM ` + tb_playlists + ` WHERE id = ?`
+// PlaylistGet retrieves a playlist and its videos from the database by ID.
+func (db *SqliteDB) PlaylistGet(id string) (PlaylistInfo, Playlist, error) {
+	query := `SELECT id, name, created_at, duplicates FROM ` + tb_playlists + ` WHERE id = ?`
 	row, err := db.QueryRow(query, id)
 	if err != nil {
-		return PlaybackClient{}, Playlist{}, fmt.Errorf("SqliteDB.PlaylistGet: %w", err)
+		return PlaylistInfo{}, Playlist{}, fmt.Errorf("SqliteDB.PlaylistGet: %w", err)
 	}
 
-	pbc := PlaybackClient{}
-	var plData string
-
+	info := PlaylistInfo{}
 	err = row.Scan(
-		&pbc.ID,
-		&pbc.Name,
-		&plData,
+		&info.ID,
+		&info.Name,
+		&info.CreatedAt,
+		&info.Duplicates,
 	)
 	if err != nil {
-		return pbc, Playlist{}, fmt.Errorf("SqliteDB.PlaylistGet: %w", err)
+		return info, Playlist{}, fmt.Errorf("SqliteDB.PlaylistGet: %w", err)
 	}
 
-	pl, err := UnmarshalPlaylists([]byte(plData))
+	pl, err := db.PlaylistItemList(info.ID)
 	if err != nil {
-		return pbc, pl, fmt.Errorf("SqliteDB.PlaylistGet: %w", err)
+		return info, pl, fmt.Errorf("SqliteDB.PlaylistGet: %w", err)
 	}
 
-	return pbc, pl, nil
+	return info, pl, nil
 }
 
-// PlaylistGetByName retrieves a playlist from the database by Name.
-func (db *SqliteDB) PlaylistGetByName(name string) (PlaybackClient, Playlist, error) {
-	query := `SELECT * FROM ` + tb_playlists + ` WHERE name = ?`
-	row, err := db.QueryRow(query, name)
+// PlaylistList retrieves every playlist from the database, oldest first.
+func (db *SqliteDB) PlaylistList() ([]PlaylistInfo, error) {
+	query := `SELECT id, name, created_at, duplicates FROM ` + tb_playlists + `
+		ORDER BY created_at, id`
+	rows, err := db.Query(query)
 	if err != nil {
-		return PlaybackClient{}, Playlist{}, fmt.Errorf("SqliteDB.PlaylistGet: %w", err)
+		return nil, fmt.Errorf("SqliteDB.PlaylistList: %w", err)
 	}
+	defer rows.Close()
 
-	pbc := PlaybackClient{}
-	var plData string
+	var infos []PlaylistInfo
+	for rows.Next() {
+		info := PlaylistInfo{}
+		if err := rows.Scan(&info.ID, &info.Name, &info.CreatedAt, &info.Duplicates); err != nil {
+			return nil, fmt.Errorf("SqliteDB.PlaylistList: %w", err)
+		}
 
-	err = row.Scan(
-		&pbc.ID,
-		&pbc.Name,
-		&plData,
+		infos = append(infos, info)
+	}
+
+	return infos, rows.Err()
+}
+
+// PlaylistRename changes the name of a playlist.
+func (db *SqliteDB) PlaylistRename(id string, name string) error {
+	if id == "" {
+		return fmt.Errorf("SqliteDB.PlaylistRename: %w", ErrInvalidID)
+	}
+
+	query := `UPDATE ` + tb_playlists + ` SET name = ? WHERE id = ?`
+	if _, err := db.Exec(query, name, id); err != nil {
+		return fmt.Errorf("SqliteDB.PlaylistRename: %w", err)
+	}
+
+	return nil
+}
+
+// PlaylistSetDuplicates changes a playlist's duplicate policy.
+func (db *SqliteDB) PlaylistSetDuplicates(id string, policy DuplicatePolicy) error {
+	if id == "" {
+		return fmt.Errorf("SqliteDB.PlaylistSetDuplicates: %w", ErrInvalidID)
+	}
+
+	query := `UPDATE ` + tb_playlists + ` SET duplicates = ? WHERE id = ?`
+	if _, err := db.Exec(query, policy, id); err != nil {
+		return fmt.Errorf("SqliteDB.PlaylistSetDuplicates: %w", err)
+	}
+
+	return nil
+}
+
+// PlaylistDelete deletes a playlist and its videos from the database by ID. Playback clients using
+// the playlist are left without an active playlist.
+func (db *SqliteDB) PlaylistDelete(id string) error {
+	if id == "" {
+		return fmt.Errorf("SqliteDB.PlaylistDelete: %w", ErrInvalidID)
+	}
+
+	query := `DELETE FROM ` + tb_playlists + ` WHERE id = ?`
+	if _, err := db.Exec(query, id); err != nil {
+		return fmt.Errorf("SqliteDB.PlaylistDelete: %w", err)
+	}
+
+	return nil
+}
+
+// ############################################################################################## //
+// ####################################    Playlist Items    #################################### //
+// ############################################################################################## //
+
+// PlaylistItemList retrieves the videos in the playlist in play order.
+func (db *SqliteDB) PlaylistItemList(playlistID string) (Playlist, error) {
+	query := `SELECT id, added_at, added_by, added_from, video_id, title, author_name, thumbnail_url,
+		start_seconds, end_seconds, loop, duration_seconds,
+		(SELECT COUNT(*) FROM ` + tb_votes + ` WHERE item_id = i.id AND vote > 0),
+		(SELECT COUNT(*) FROM ` + tb_votes + ` WHERE item_id = i.id AND vote < 0)
+		FROM ` + tb_playlist_items + ` i WHERE playlist_id = ? ORDER BY position, id`
+	rows, err := db.Query(query, playlistID)
+	if err != nil {
+		return nil, fmt.Errorf("SqliteDB.PlaylistItemList: %w", err)
+	}
+	defer rows.Close()
+
+	pl := NewPlaylist()
+	for rows.Next() {
+		var d VideoDetails
+		err := rows.Scan(
+			&d.ItemID,
+			&d.AddedAt,
+			&d.AddedBy,
+			&d.AddedFrom,
+			&d.VideoID,
+			&d.Title,
+			&d.AuthorName,
+			&d.ThumbnailURL,
+			&d.StartSeconds,
+			&d.EndSeconds,
+			&d.Loop,
+			&d.DurationSeconds,
+			&d.Upvotes,
+			&d.Downvotes,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("SqliteDB.PlaylistItemList: %w", err)
+		}
+
+		pl = append(pl, d)
+	}
+
+	return pl, rows.Err()
+}
+
+// PlaylistItemAdd adds a video to the playlist and returns its item ID. If front is true the video
+// is placed ahead of every other video, otherwise it is placed at the end.
+func (db *SqliteDB) PlaylistItemAdd(playlistID string, d VideoDetails, front bool) (int64, error) {
+	if playlistID == "" {
+		return 0, fmt.Errorf("SqliteDB.PlaylistItemAdd: playlistID - %w", ErrParamEmpty)
+	}
+
+	if d.VideoID == "" {
+		return 0, fmt.Errorf("SqliteDB.PlaylistItemAdd: VideoID - %w", ErrParamEmpty)
+	}
+
+	// Positions don't need to be contiguous so we can add to either end without touching any other
+	// row.
+	position := `COALESCE(MAX(position), 0) + 1`
+	if front {
+		position = `COALESCE(MIN(position), 1) - 1`
+	}
+
+	query := `INSERT INTO ` + tb_playlist_items + ` (playlist_id, position, added_at, added_by,
+		added_from, video_id, title, author_name, thumbnail_url, start_seconds, end_seconds, loop,
+		duration_seconds)
+		SELECT ?, ` + position + `, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? FROM ` + tb_playlist_items + `
+		WHERE playlist_id = ?`
+	res, err := db.Exec(
+		query,
+		playlistID,
+		d.AddedAt,
+		d.AddedBy,
+		d.AddedFrom,
+		d.VideoID,
+		d.Title,
+		d.AuthorName,
+		d.ThumbnailURL,
+		d.StartSeconds,
+		d.EndSeconds,
+		d.Loop,
+		d.DurationSeconds,
+		playlistID,
 	)
 	if err != nil {
-		return pbc, Playlist{}, fmt.Errorf("SqliteDB.PlaylistGet: %w", err)
+		return 0, fmt.Errorf("SqliteDB.PlaylistItemAdd: %w", err)
 	}
 
-	pl, err := UnmarshalPlaylists([]byte(plData))
+	itemID, err := res.LastInsertId()
 	if err != nil {
-		return pbc, pl, fmt.Errorf("SqliteDB.PlaylistGet: %w", err)
+		return 0, fmt.Errorf("SqliteDB.PlaylistItemAdd: %w", err)
 	}
 
-	return pbc, pl, nil
+	return itemID, nil
 }
 
-func (db *SqliteDB) PlaylistGetAll() (map[PlaybackClient]Playlist, error) {
-	query := `SELECT * FROM ` + tb_playlists
-	rows, err := db.Query(query)
+// PlaylistItemAddBatch saves the playlist's new order in a single transaction. Items in pl without
+// an item ID are added to the playlist, then every item in pl is renumbered to match its place in
+// pl. Items which are not in pl are left alone unless replace is true, in which case they are
+// deleted first. The returned playlist has the new item IDs filled in.
+func (db *SqliteDB) PlaylistItemAddBatch(playlistID string, pl Playlist, replace bool) (Playlist, error) {
+	if playlistID == "" {
+		return pl, fmt.Errorf("SqliteDB.PlaylistItemAddBatch: playlistID - %w", ErrParamEmpty)
+	}
+
+	tx, err := db.BeginTx(db.ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("SqliteDB.PlaylistGetAll: %w", err)
+		return pl, fmt.Errorf("SqliteDB.PlaylistItemAddBatch: %w", err)
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	pls := make(map[PlaybackClient]Playlist, 0)
-	for rows.Next() {
-		var pbc PlaybackClient
-		var plData string
+	if replace {
+		query := `DELETE FROM ` + tb_playlist_items + ` WHERE playlist_id = ?`
+		if _, err := tx.ExecContext(db.ctx, query, playlistID); err != nil {
+			return pl, fmt.Errorf("SqliteDB.PlaylistItemAddBatch: %w", err)
+		}
+	}
+
+	pl = slices.Clone(pl)
+	insert := `INSERT INTO ` + tb_playlist_items + ` (playlist_id, position, added_at, added_by,
+		added_from, video_id, title, author_name, thumbnail_url, start_seconds, end_seconds, loop,
+		duration_seconds) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	update := `UPDATE ` + tb_playlist_items + ` SET position = ? WHERE playlist_id = ? AND id = ?`
+	for i, d := range pl {
+		if d.ItemID != 0 {
+			if _, err := tx.ExecContext(db.ctx, update, i+1, playlistID, d.ItemID); err != nil {
+				return pl, fmt.Errorf("SqliteDB.PlaylistItemAddBatch: %w", err)
+			}
+
+			continue
+		}
 
-		err = rows.Scan(
-			&pbc.ID,
-			&pbc.Name,
-			&plData,
+		res, err := tx.ExecContext(
+			db.ctx,
+			insert,
+			playlistID,
+			i+1,
+			d.AddedAt,
+			d.AddedBy,
+			d.AddedFrom,
+			d.VideoID,
+			d.Title,
+			d.AuthorName,
+			d.ThumbnailURL,
+			d.StartSeconds,
+			d.EndSeconds,
+			d.Loop,
+			d.DurationSeconds,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("SqliteDB.PlaylistGetAll: %w", err)
+			return pl, fmt.Errorf("SqliteDB.PlaylistItemAddBatch: %w", err)
 		}
 
-		pl, err := UnmarshalPlaylists([]byte(plData))
-		if err != nil {
-			return nil, fmt.Errorf("SqliteDB.PlaylistGetAll: %w", err)
+		if pl[i].ItemID, err = res.LastInsertId(); err != nil {
+			return pl, fmt.Errorf("SqliteDB.PlaylistItemAddBatch: %w", err)
 		}
+	}
 
-		pls[pbc] = pl
+	if err := tx.Commit(); err != nil {
+		return pl, fmt.Errorf("SqliteDB.PlaylistItemAddBatch: %w", err)
 	}
 
-	return pls, nil
+	return pl, nil
 }
 
-// PlaylistUpdate updates a user in the database and returns the updated user data.
-func (db *SqliteDB) PlaylistUpdate(pbc PlaybackClient, pl Playlist) error {
-	if pbc.ID == "" {
-		return fmt.Errorf("SqliteDB.PlaylistUpdate: %w", ErrInvalidID)
-	}
-
-	if pbc.Name == "" {
-		return fmt.Errorf("SqliteDB.PlaylistUpdate: %w", ErrInvalidID)
+// PlaylistItemUpdateMetadata updates the metadata of every queued copy of the video.
+func (db *SqliteDB) PlaylistItemUpdateMetadata(m VideoMetadata) error {
+	if m.VideoID == "" {
+		return fmt.Errorf("SqliteDB.PlaylistItemUpdateMetadata: VideoID - %w", ErrParamEmpty)
 	}
 
-	plData, err := json.Marshal(pl)
+	// A duration the metadata doesn't have is left alone since a player may have reported it.
+	query := `UPDATE ` + tb_playlist_items + ` SET title = ?, author_name = ?, thumbnail_url = ?,
+		duration_seconds = COALESCE(NULLIF(?, 0), duration_seconds) WHERE video_id = ?`
+	_, err := db.Exec(query, m.Title, m.AuthorName, m.ThumbnailURL, m.DurationSeconds, m.VideoID)
 	if err != nil {
-		return fmt.Errorf("SqliteDB.PlaylistUpdate: %w", err)
+		return fmt.Errorf("SqliteDB.PlaylistItemUpdateMetadata: %w", err)
 	}
 
-	query := `UPDATE ` + tb_playlists + ` SET name = ?, playlist = ? WHERE id = ?`
-	if _, err := db.Exec(query, pbc.Name, plData, pbc.ID); err != nil {
-		return fmt.Errorf("SqliteDB.PlaylistUpdate: %w", err)
+	return nil
+}
+
+// PlaylistItemSetDuration sets the duration of every queued copy of the video which does not have
+// one.
+func (db *SqliteDB) PlaylistItemSetDuration(vid string, seconds int) error {
+	query := `UPDATE ` + tb_playlist_items + ` SET duration_seconds = ?
+		WHERE video_id = ? AND duration_seconds = 0`
+	if _, err := db.Exec(query, seconds, vid); err != nil {
+		return fmt.Errorf("SqliteDB.PlaylistItemSetDuration: %w", err)
 	}
 
 	return nil
 }
 
-// PlaylistDelete deletes a user from the database by ID.
-func (db *SqliteDB) PlaylistDelete(id string) error {
-	if id == "" {
-		return fmt.Errorf("SqliteDB.PlaylistUpdate: %w", ErrInvalidID)
+// PlaylistItemDelete removes an item from the playlist.
+func (db *SqliteDB) PlaylistItemDelete(playlistID string, itemID int64) error {
+	if playlistID == "" {
+		return fmt.Errorf("SqliteDB.PlaylistItemDelete: playlistID - %w", ErrParamEmpty)
 	}
 
-	if err := db.PlaylistIsUnique(id); err != nil {
-		return fmt.Errorf("SqliteDB.PlaylistDelete: %w", err)
+	query := `DELETE FROM ` + tb_playlist_items + ` WHERE playlist_id = ? AND id = ?`
+	if _, err := db.Exec(query, playlistID, itemID); err != nil {
+		return fmt.Errorf("SqliteDB.PlaylistItemDelete: %w", err)
 	}
 
-	query := `DELETE FROM ` + tb_playlists + ` WHERE id = ?`
-	if _, err := db.Exec(query, id); err != nil {
-		return fmt.Errorf("SqliteDB.PlaylistDelete: %w", err)
+	return nil
+}
+
+// PlaylistItemClear removes every video from the playlist.
+func (db *SqliteDB) PlaylistItemClear(playlistID string) error {
+	if playlistID == "" {
+		return fmt.Errorf("SqliteDB.PlaylistItemClear: playlistID - %w", ErrParamEmpty)
+	}
+
+	query := `DELETE FROM ` + tb_playlist_items + ` WHERE playlist_id = ?`
+	if _, err := db.Exec(query, playlistID); err != nil {
+		return fmt.Errorf("SqliteDB.PlaylistItemClear: %w", err)
 	}
 
 	return nil
 }
 
-// PlaybackClientList retrieves a list of all playback clients from the database.
-func (db *SqliteDB) PlaybackClientList() ([]PlaybackClient, error) {
-	query := `SELECT id, name FROM ` + tb_playlists
-	rows, err := db.Query(query)
+// PlaylistItemReorder renumbers the positions of the playlist's videos to match pl. All positions
+// are updated in a single transaction.
+func (db *SqliteDB) PlaylistItemReorder(playlistID string, pl Playlist) error {
+	if playlistID == "" {
+		return fmt.Errorf("SqliteDB.PlaylistItemReorder: playlistID - %w", ErrParamEmpty)
+	}
+
+	tx, err := db.BeginTx(db.ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("SqliteDB.PlaybackClientList: %w", err)
+		return fmt.Errorf("SqliteDB.PlaylistItemReorder: %w", err)
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	var pbcs []PlaybackClient
-	for rows.Next() {
-		var pbc PlaybackClient
-		err = rows.Scan(&pbc.ID, &pbc.Name)
-		if err != nil {
-			return nil, fmt.Errorf("SqliteDB.PlaybackClientList: %w", err)
+	query := `UPDATE ` + tb_playlist_items + ` SET position = ? WHERE playlist_id = ? AND id = ?`
+	for i, d := range pl {
+		if _, err := tx.ExecContext(db.ctx, query, i+1, playlistID, d.ItemID); err != nil {
+			return fmt.Errorf("SqliteDB.PlaylistItemReorder: %w", err)
 		}
+	}
 
-		pbcs = append(pbcs, pbc)
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("SqliteDB.PlaylistItemReorder: %w", err)
 	}
 
-	return pbcs, nil
+	return nil
 }
 
 // ############################################################################################## //
 // ####################################         WOL          #################################### //
 // ############################################################################################## //
 
-// WOLMigrate creates the 'wol' table if it does not exist.
-func (db *SqliteDB) WOLMigrate() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS ` + tb_wol + ` (
-		pbc_id VARCHAR(11) NOT NULL PRIMARY KEY,
-		alias VARCHAR(255) NOT NULL,
-		mac VARCHAR(17) NOT NULL,
-		interface VARCHAR(15) NOT NULL,
-		port INTEGER NOT NULL DEFAULT 9,
-		enabled BOOLEAN NOT NULL DEFAULT 1,
-		FOREIGN KEY (pbc_id) REFERENCES ` + tb_playlists + `(id) ON DELETE CASCADE
-	);
-	CREATE INDEX IF NOT EXISTS idx_wol_pbc_id ON ` + tb_wol + ` (pbc_id);
-	CREATE INDEX IF NOT EXISTS idx_wol_mac ON ` + tb_wol + ` (mac);`
-
-	if _, err := db.Exec(query); err != nil {
-		return fmt.Errorf("SqliteDB.WOLMigrate: %w", err)
-	}
-
-	return nil
-}
-
 // WOLIsUnique checks if the WOL mac address is unique in the database.
 func (db *SqliteDB) WOLIsUnique(mac string) error {
 	if mac == "" {
@@ -525,26 +786,6 @@ func (db *SqliteDB) WOLDelete(pbcid string) error {
 // ####################################         CEC          #################################### //
 // ############################################################################################## //
 
-// WOLMigrate creates the 'wol' table if it does not exist.
-func (db *SqliteDB) CECMigrate() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS ` + tb_cec + ` (
-		pbc_id VARCHAR(11) NOT NULL PRIMARY KEY,
-		alias VARCHAR(14) NOT NULL,
-		device VARCHAR(14) NOT NULL,
-		logical_addr VARCHAR(7) NOT NULL,
-		physical_addr VARCHAR(7) NOT NULL,
-		FOREIGN KEY (pbc_id) REFERENCES ` + tb_playlists + `(id) ON DELETE CASCADE
-	);
-	CREATE INDEX IF NOT EXISTS idx_cec_pbc_id ON ` + tb_cec + ` (pbc_id);`
-
-	if _, err := db.Exec(query); err != nil {
-		return fmt.Errorf("SqliteDB.CECMigrate: %w", err)
-	}
-
-	return nil
-}
-
 // CECIsUnique checks if the CEC device is unique in the database.
 func (db *SqliteDB) CECIsUnique(pbcID string) error {
 	if pbcID == "" {
@@ -630,3 +871,935 @@ func (db *SqliteDB) CECDelete(pbcid string) error {
 
 	return nil
 }
+
+// ############################################################################################## //
+// ####################################       History        #################################### //
+// ############################################################################################## //
+
+// HistoryCreate adds a played video to the history.
+func (db *SqliteDB) HistoryCreate(entry HistoryEntry) error {
+	if entry.PBCID == "" {
+		return fmt.Errorf("SqliteDB.HistoryCreate: PBCID - %w", ErrParamEmpty)
+	}
+
+	if entry.VideoID == "" {
+		return fmt.Errorf("SqliteDB.HistoryCreate: VideoID - %w", ErrParamEmpty)
+	}
+
+	query := `INSERT INTO ` + tb_history + ` (pbc_id, video_id, title, author_name, thumbnail_url,
+		start_seconds, end_seconds, loop, started_at, ended_at, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := db.Exec(
+		query,
+		entry.PBCID,
+		entry.VideoID,
+		entry.Title,
+		entry.AuthorName,
+		entry.ThumbnailURL,
+		entry.StartSeconds,
+		entry.EndSeconds,
+		entry.Loop,
+		entry.StartedAt,
+		entry.EndedAt,
+		entry.Status,
+	)
+	if err != nil {
+		return fmt.Errorf("SqliteDB.HistoryCreate: %w", err)
+	}
+
+	return nil
+}
+
+const historyColumns = `id, pbc_id, video_id, title, author_name, thumbnail_url, start_seconds,
+	end_seconds, loop, started_at, ended_at, status`
+
+func scanHistoryEntry(row interface{ Scan(...any) error }) (HistoryEntry, error) {
+	entry := HistoryEntry{}
+	err := row.Scan(
+		&entry.ID,
+		&entry.PBCID,
+		&entry.VideoID,
+		&entry.Title,
+		&entry.AuthorName,
+		&entry.ThumbnailURL,
+		&entry.StartSeconds,
+		&entry.EndSeconds,
+		&entry.Loop,
+		&entry.StartedAt,
+		&entry.EndedAt,
+		&entry.Status,
+	)
+
+	return entry, err
+}
+
+// HistoryGet retrieves a history entry from the database by ID.
+func (db *SqliteDB) HistoryGet(id int64) (HistoryEntry, error) {
+	query := `SELECT ` + historyColumns + ` FROM ` + tb_history + ` WHERE id = ?`
+	row, err := db.QueryRow(query, id)
+	if err != nil {
+		return HistoryEntry{}, fmt.Errorf("SqliteDB.HistoryGet: %w", err)
+	}
+
+	entry, err := scanHistoryEntry(row)
+	if err != nil {
+		return entry, fmt.Errorf("SqliteDB.HistoryGet: %w", err)
+	}
+
+	return entry, nil
+}
+
+// HistoryList fills page with the playback client's history, newest first. The page must come
+// from NewHistoryPage.
+func (db *SqliteDB) HistoryList(pbcID string, page HistoryPage) (HistoryPage, error) {
+	if pbcID == "" {
+		return page, fmt.Errorf("SqliteDB.HistoryList: pbcID - %w", ErrParamEmpty)
+	}
+
+	row, err := db.QueryRow(`SELECT COUNT(*) FROM `+tb_history+` WHERE pbc_id = ?`, pbcID)
+	if err != nil {
+		return page, fmt.Errorf("SqliteDB.HistoryList: %w", err)
+	}
+
+	if err := row.Scan(&page.Total); err != nil {
+		return page, fmt.Errorf("SqliteDB.HistoryList: %w", err)
+	}
+
+	query := `SELECT ` + historyColumns + ` FROM ` + tb_history + `
+		WHERE pbc_id = ? ORDER BY ended_at DESC, id DESC LIMIT ? OFFSET ?`
+	rows, err := db.Query(query, pbcID, page.PerPage, (page.Page-1)*page.PerPage)
+	if err != nil {
+		return page, fmt.Errorf("SqliteDB.HistoryList: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanHistoryEntry(rows)
+		if err != nil {
+			return page, fmt.Errorf("SqliteDB.HistoryList: %w", err)
+		}
+
+		page.Entries = append(page.Entries, entry)
+	}
+
+	return page, rows.Err()
+}
+
+// HistoryFinishedList returns the most recent videos the playback client played through, newest
+// first. Each video is only returned once.
+func (db *SqliteDB) HistoryFinishedList(pbcID string, limit int) ([]HistoryEntry, error) {
+	if pbcID == "" {
+		return nil, fmt.Errorf("SqliteDB.HistoryFinishedList: pbcID - %w", ErrParamEmpty)
+	}
+
+	query := `SELECT ` + historyColumns + ` FROM ` + tb_history + ` WHERE id IN (
+		SELECT MAX(id) FROM ` + tb_history + ` WHERE pbc_id = ? AND status = ? GROUP BY video_id
+	) ORDER BY ended_at DESC, id DESC LIMIT ?`
+	rows, err := db.Query(query, pbcID, HistoryFinished, limit)
+	if err != nil {
+		return nil, fmt.Errorf("SqliteDB.HistoryFinishedList: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []HistoryEntry
+	for rows.Next() {
+		entry, err := scanHistoryEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("SqliteDB.HistoryFinishedList: %w", err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// HistoryFavoritesList returns the latest finished entry of each video the playback client has
+// played through at least twice. If there are more than limit such videos only the most played are
+// returned.
+func (db *SqliteDB) HistoryFavoritesList(pbcID string, limit int) ([]HistoryEntry, error) {
+	if pbcID == "" {
+		return nil, fmt.Errorf("SqliteDB.HistoryFavoritesList: pbcID - %w", ErrParamEmpty)
+	}
+
+	query := `SELECT ` + historyColumns + ` FROM ` + tb_history + ` WHERE id IN (
+		SELECT MAX(id) FROM ` + tb_history + ` WHERE pbc_id = ? AND status = ? GROUP BY video_id
+		HAVING COUNT(*) > 1 ORDER BY COUNT(*) DESC, MAX(id) DESC LIMIT ?
+	) ORDER BY ended_at DESC, id DESC`
+	rows, err := db.Query(query, pbcID, HistoryFinished, limit)
+	if err != nil {
+		return nil, fmt.Errorf("SqliteDB.HistoryFavoritesList: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []HistoryEntry
+	for rows.Next() {
+		entry, err := scanHistoryEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("SqliteDB.HistoryFavoritesList: %w", err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// ############################################################################################## //
+// ####################################    Video Metadata    #################################### //
+// ############################################################################################## //
+
+// MetadataGet retrieves cached video metadata from the database by video ID.
+func (db *SqliteDB) MetadataGet(vid string) (VideoMetadata, error) {
+	query := `SELECT video_id, title, author_name, thumbnail_url, duration_seconds, fetched_at FROM ` +
+		tb_video_metadata + ` WHERE video_id = ?`
+	row, err := db.QueryRow(query, vid)
+	if err != nil {
+		return VideoMetadata{}, fmt.Errorf("SqliteDB.MetadataGet: %w", err)
+	}
+
+	m := VideoMetadata{}
+	err = row.Scan(
+		&m.VideoID,
+		&m.Title,
+		&m.AuthorName,
+		&m.ThumbnailURL,
+		&m.DurationSeconds,
+		&m.FetchedAt,
+	)
+	if err != nil {
+		return m, fmt.Errorf("SqliteDB.MetadataGet: %w", err)
+	}
+
+	return m, nil
+}
+
+// MetadataPut creates or replaces the cached metadata for a video.
+func (db *SqliteDB) MetadataPut(m VideoMetadata) error {
+	if m.VideoID == "" {
+		return fmt.Errorf("SqliteDB.MetadataPut: VideoID - %w", ErrParamEmpty)
+	}
+
+	query := `INSERT OR REPLACE INTO ` + tb_video_metadata + ` (video_id, title, author_name,
+		thumbnail_url, duration_seconds, fetched_at) VALUES (?, ?, ?, ?, ?, ?)`
+	_, err := db.Exec(query, m.VideoID, m.Title, m.AuthorName, m.ThumbnailURL, m.DurationSeconds, m.FetchedAt)
+	if err != nil {
+		return fmt.Errorf("SqliteDB.MetadataPut: %w", err)
+	}
+
+	return nil
+}
+
+// MetadataSetDuration sets the duration of a cached video if it does not have one.
+func (db *SqliteDB) MetadataSetDuration(vid string, seconds int) error {
+	query := `UPDATE ` + tb_video_metadata + ` SET duration_seconds = ?
+		WHERE video_id = ? AND duration_seconds = 0`
+	if _, err := db.Exec(query, seconds, vid); err != nil {
+		return fmt.Errorf("SqliteDB.MetadataSetDuration: %w", err)
+	}
+
+	return nil
+}
+
+// MetadataDelete removes the cached metadata for a video.
+func (db *SqliteDB) MetadataDelete(vid string) error {
+	if vid == "" {
+		return fmt.Errorf("SqliteDB.MetadataDelete: %w", ErrInvalidID)
+	}
+
+	query := `DELETE FROM ` + tb_video_metadata + ` WHERE video_id = ?`
+	if _, err := db.Exec(query, vid); err != nil {
+		return fmt.Errorf("SqliteDB.MetadataDelete: %w", err)
+	}
+
+	return nil
+}
+
+// MetadataDeleteBefore removes all cached metadata fetched before t and returns the number of
+// entries removed.
+func (db *SqliteDB) MetadataDeleteBefore(t time.Time) (int64, error) {
+	query := `DELETE FROM ` + tb_video_metadata + ` WHERE fetched_at <= ?`
+	r, err := db.Exec(query, t)
+	if err != nil {
+		return 0, fmt.Errorf("SqliteDB.MetadataDeleteBefore: %w", err)
+	}
+
+	n, err := r.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("SqliteDB.MetadataDeleteBefore: %w", err)
+	}
+
+	return n, nil
+}
+
+// ############################################################################################## //
+// ###################################    Playback Settings    ################################## //
+// ############################################################################################## //
+
+const settingsColumns = `pbc_id, mode, max_length, max_per_submitter, max_duration, allowlist_only,
+	fallback_source, fallback_playlist_id`
+
+func scanSettings(row interface{ Scan(...any) error }, s *PBCSettings) error {
+	return row.Scan(
+		&s.PBCID,
+		&s.Mode,
+		&s.MaxLength,
+		&s.MaxPerSubmitter,
+		&s.MaxDuration,
+		&s.AllowlistOnly,
+		&s.FallbackSource,
+		&s.FallbackPlaylistID,
+	)
+}
+
+// SettingsGet retrieves a playback client's settings from the database. If the playback client has
+// no saved settings the defaults are returned.
+func (db *SqliteDB) SettingsGet(pbcID string) (PBCSettings, error) {
+	if pbcID == "" {
+		return PBCSettings{}, fmt.Errorf("SqliteDB.SettingsGet: pbcID - %w", ErrParamEmpty)
+	}
+
+	query := `SELECT ` + settingsColumns + ` FROM ` + tb_pbc_settings + ` WHERE pbc_id = ?`
+	row, err := db.QueryRow(query, pbcID)
+	if err != nil {
+		return PBCSettings{}, fmt.Errorf("SqliteDB.SettingsGet: %w", err)
+	}
+
+	s := NewPBCSettings(pbcID)
+	if err := scanSettings(row, &s); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return s, nil
+		}
+
+		return s, fmt.Errorf("SqliteDB.SettingsGet: %w", err)
+	}
+
+	return s, nil
+}
+
+// SettingsList retrieves the saved settings for every playback client.
+func (db *SqliteDB) SettingsList() ([]PBCSettings, error) {
+	rows, err := db.Query(`SELECT ` + settingsColumns + ` FROM ` + tb_pbc_settings)
+	if err != nil {
+		return nil, fmt.Errorf("SqliteDB.SettingsList: %w", err)
+	}
+	defer rows.Close()
+
+	var list []PBCSettings
+	for rows.Next() {
+		s := PBCSettings{}
+		if err := scanSettings(rows, &s); err != nil {
+			return nil, fmt.Errorf("SqliteDB.SettingsList: %w", err)
+		}
+
+		list = append(list, s)
+	}
+
+	return list, rows.Err()
+}
+
+// SettingsSave creates or replaces a playback client's settings.
+func (db *SqliteDB) SettingsSave(s PBCSettings) error {
+	if s.PBCID == "" {
+		return fmt.Errorf("SqliteDB.SettingsSave: pbcID - %w", ErrParamEmpty)
+	}
+
+	query := `INSERT OR REPLACE INTO ` + tb_pbc_settings + ` (` + settingsColumns + `)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := db.Exec(
+		query,
+		s.PBCID,
+		s.Mode,
+		s.MaxLength,
+		s.MaxPerSubmitter,
+		s.MaxDuration,
+		s.AllowlistOnly,
+		s.FallbackSource,
+		s.FallbackPlaylistID,
+	)
+	if err != nil {
+		return fmt.Errorf("SqliteDB.SettingsSave: %w", err)
+	}
+
+	return nil
+}
+
+// ############################################################################################## //
+// ####################################        Votes         #################################### //
+// ############################################################################################## //
+
+// VoteSet saves the voter's vote on the item, or deletes it if vote is 0, and returns the item's
+// new upvote and downvote counts.
+func (db *SqliteDB) VoteSet(itemID int64, voter string, vote int) (int, int, error) {
+	if voter == "" {
+		return 0, 0, fmt.Errorf("SqliteDB.VoteSet: voter - %w", ErrParamEmpty)
+	}
+
+	tx, err := db.BeginTx(db.ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("SqliteDB.VoteSet: %w", err)
+	}
+	defer tx.Rollback()
+
+	if vote == 0 {
+		query := `DELETE FROM ` + tb_votes + ` WHERE item_id = ? AND voter = ?`
+		_, err = tx.ExecContext(db.ctx, query, itemID, voter)
+	} else {
+		query := `INSERT OR REPLACE INTO ` + tb_votes + ` (item_id, voter, vote, voted_at)
+			VALUES (?, ?, ?, ?)`
+		_, err = tx.ExecContext(db.ctx, query, itemID, voter, vote, time.Now())
+	}
+
+	if err != nil {
+		return 0, 0, fmt.Errorf("SqliteDB.VoteSet: %w", err)
+	}
+
+	var up, down int
+	query := `SELECT COUNT(CASE WHEN vote > 0 THEN 1 END), COUNT(CASE WHEN vote < 0 THEN 1 END)
+		FROM ` + tb_votes + ` WHERE item_id = ?`
+	if err := tx.QueryRowContext(db.ctx, query, itemID).Scan(&up, &down); err != nil {
+		return 0, 0, fmt.Errorf("SqliteDB.VoteSet: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("SqliteDB.VoteSet: %w", err)
+	}
+
+	return up, down, nil
+}
+
+// ############################################################################################## //
+// ####################################        Trash         #################################### //
+// ############################################################################################## //
+
+// TrashAdd moves the entries' items from their playlist into the trash in a single transaction.
+// The entries are given a new batch number so they can be restored together.
+func (db *SqliteDB) TrashAdd(entries []TrashEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	tx, err := db.BeginTx(db.ctx, nil)
+	if err != nil {
+		return fmt.Errorf("SqliteDB.TrashAdd: %w", err)
+	}
+	defer tx.Rollback()
+
+	var batch int64
+	row := tx.QueryRowContext(db.ctx, `SELECT COALESCE(MAX(batch), 0) + 1 FROM `+tb_trash)
+	if err := row.Scan(&batch); err != nil {
+		return fmt.Errorf("SqliteDB.TrashAdd: %w", err)
+	}
+
+	insert := `INSERT INTO ` + tb_trash + ` (batch, pbc_id, playlist_id, position, item_id, added_at,
+		added_by, added_from, video_id, title, author_name, thumbnail_url, start_seconds, end_seconds,
+		loop, duration_seconds, reason, deleted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	// The item's votes are kept with it since deleting the item deletes them.
+	votes := `INSERT INTO ` + tb_trash_votes + ` (batch, item_id, voter, vote, voted_at)
+		SELECT ?, item_id, voter, vote, voted_at FROM ` + tb_votes + ` WHERE item_id = ?`
+	remove := `DELETE FROM ` + tb_playlist_items + ` WHERE playlist_id = ? AND id = ?`
+	for _, e := range entries {
+		_, err := tx.ExecContext(
+			db.ctx,
+			insert,
+			batch,
+			e.PBCID,
+			e.PlaylistID,
+			e.Position,
+			e.ItemID,
+			e.AddedAt,
+			e.AddedBy,
+			e.AddedFrom,
+			e.VideoID,
+			e.Title,
+			e.AuthorName,
+			e.ThumbnailURL,
+			e.StartSeconds,
+			e.EndSeconds,
+			e.Loop,
+			e.DurationSeconds,
+			e.Reason,
+			e.DeletedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("SqliteDB.TrashAdd: %w", err)
+		}
+
+		if _, err := tx.ExecContext(db.ctx, votes, batch, e.ItemID); err != nil {
+			return fmt.Errorf("SqliteDB.TrashAdd: %w", err)
+		}
+
+		if _, err := tx.ExecContext(db.ctx, remove, e.PlaylistID, e.ItemID); err != nil {
+			return fmt.Errorf("SqliteDB.TrashAdd: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("SqliteDB.TrashAdd: %w", err)
+	}
+
+	return nil
+}
+
+const trashColumns = `id, batch, pbc_id, playlist_id, position, item_id, added_at, added_by,
+	added_from, video_id, title, author_name, thumbnail_url, start_seconds, end_seconds, loop,
+	duration_seconds, reason, deleted_at,
+	(SELECT COUNT(*) FROM ` + tb_trash_votes + ` v WHERE v.batch = ` + tb_trash + `.batch
+		AND v.item_id = ` + tb_trash + `.item_id AND v.vote > 0),
+	(SELECT COUNT(*) FROM ` + tb_trash_votes + ` v WHERE v.batch = ` + tb_trash + `.batch
+		AND v.item_id = ` + tb_trash + `.item_id AND v.vote < 0)`
+
+func scanTrashEntry(row interface{ Scan(...any) error }) (TrashEntry, error) {
+	e := TrashEntry{}
+	err := row.Scan(
+		&e.ID,
+		&e.Batch,
+		&e.PBCID,
+		&e.PlaylistID,
+		&e.Position,
+		&e.ItemID,
+		&e.AddedAt,
+		&e.AddedBy,
+		&e.AddedFrom,
+		&e.VideoID,
+		&e.Title,
+		&e.AuthorName,
+		&e.ThumbnailURL,
+		&e.StartSeconds,
+		&e.EndSeconds,
+		&e.Loop,
+		&e.DurationSeconds,
+		&e.Reason,
+		&e.DeletedAt,
+		&e.Upvotes,
+		&e.Downvotes,
+	)
+
+	return e, err
+}
+
+func (db *SqliteDB) trashQuery(caller string, query string, args ...any) ([]TrashEntry, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("SqliteDB.%s: %w", caller, err)
+	}
+	defer rows.Close()
+
+	var entries []TrashEntry
+	for rows.Next() {
+		e, err := scanTrashEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("SqliteDB.%s: %w", caller, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// TrashList retrieves the playback client's trash entries deleted after since, newest first.
+func (db *SqliteDB) TrashList(pbcID string, since time.Time) ([]TrashEntry, error) {
+	if pbcID == "" {
+		return nil, fmt.Errorf("SqliteDB.TrashList: pbcID - %w", ErrParamEmpty)
+	}
+
+	query := `SELECT ` + trashColumns + ` FROM ` + tb_trash + `
+		WHERE pbc_id = ? AND deleted_at > ? ORDER BY batch DESC, position`
+	return db.trashQuery("TrashList", query, pbcID, since)
+}
+
+// TrashLatest retrieves the entries of the playback client's newest batch deleted after since, in
+// position order.
+func (db *SqliteDB) TrashLatest(pbcID string, since time.Time) ([]TrashEntry, error) {
+	if pbcID == "" {
+		return nil, fmt.Errorf("SqliteDB.TrashLatest: pbcID - %w", ErrParamEmpty)
+	}
+
+	query := `SELECT ` + trashColumns + ` FROM ` + tb_trash + ` WHERE batch = (
+			SELECT MAX(batch) FROM ` + tb_trash + ` WHERE pbc_id = ? AND deleted_at > ?
+		) ORDER BY position`
+	return db.trashQuery("TrashLatest", query, pbcID, since)
+}
+
+// TrashRestore moves a batch of trash entries back into their playlist with their old item IDs
+// and votes and renumbers the playlist to match pl in a single transaction. pl must hold the
+// playlist's items, including the restored ones, in their new order.
+func (db *SqliteDB) TrashRestore(batch int64, playlistID string, pl Playlist) error {
+	if playlistID == "" {
+		return fmt.Errorf("SqliteDB.TrashRestore: playlistID - %w", ErrParamEmpty)
+	}
+
+	tx, err := db.BeginTx(db.ctx, nil)
+	if err != nil {
+		return fmt.Errorf("SqliteDB.TrashRestore: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO ` + tb_playlist_items + ` (id, playlist_id, position, added_at, added_by,
+		added_from, video_id, title, author_name, thumbnail_url, start_seconds, end_seconds, loop,
+		duration_seconds)
+		SELECT item_id, playlist_id, position, added_at, added_by, added_from, video_id, title,
+			author_name, thumbnail_url, start_seconds, end_seconds, loop, duration_seconds
+		FROM ` + tb_trash + ` WHERE batch = ?`
+	if _, err := tx.ExecContext(db.ctx, query, batch); err != nil {
+		return fmt.Errorf("SqliteDB.TrashRestore: %w", err)
+	}
+
+	query = `INSERT INTO ` + tb_votes + ` (item_id, voter, vote, voted_at)
+		SELECT item_id, voter, vote, voted_at FROM ` + tb_trash_votes + ` WHERE batch = ?`
+	if _, err := tx.ExecContext(db.ctx, query, batch); err != nil {
+		return fmt.Errorf("SqliteDB.TrashRestore: %w", err)
+	}
+
+	query = `UPDATE ` + tb_playlist_items + ` SET position = ? WHERE playlist_id = ? AND id = ?`
+	for i, d := range pl {
+		if _, err := tx.ExecContext(db.ctx, query, i+1, playlistID, d.ItemID); err != nil {
+			return fmt.Errorf("SqliteDB.TrashRestore: %w", err)
+		}
+	}
+
+	for _, tb := range []string{tb_trash, tb_trash_votes} {
+		if _, err := tx.ExecContext(db.ctx, `DELETE FROM `+tb+` WHERE batch = ?`, batch); err != nil {
+			return fmt.Errorf("SqliteDB.TrashRestore: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("SqliteDB.TrashRestore: %w", err)
+	}
+
+	return nil
+}
+
+// TrashPurge deletes every trash entry deleted before the provided time, along with their votes,
+// and returns the number of entries removed.
+func (db *SqliteDB) TrashPurge(before time.Time) (int64, error) {
+	tx, err := db.BeginTx(db.ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("SqliteDB.TrashPurge: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(db.ctx, `DELETE FROM `+tb_trash+` WHERE deleted_at < ?`, before)
+	if err != nil {
+		return 0, fmt.Errorf("SqliteDB.TrashPurge: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("SqliteDB.TrashPurge: %w", err)
+	}
+
+	query := `DELETE FROM ` + tb_trash_votes + `
+		WHERE batch NOT IN (SELECT batch FROM ` + tb_trash + `)`
+	if _, err := tx.ExecContext(db.ctx, query); err != nil {
+		return 0, fmt.Errorf("SqliteDB.TrashPurge: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("SqliteDB.TrashPurge: %w", err)
+	}
+
+	return n, nil
+}
+
+// ############################################################################################## //
+// ###################################        Schedules        ################################## //
+// ############################################################################################## //
+
+const scheduleColumns = `id, name, pbc_id, cron, run_at, time_zone, action, video_id, start_seconds,
+	end_seconds, loop, playlist_id, missed, enabled, next_run, last_run, last_error, created_at`
+
+func scanSchedule(row interface{ Scan(...any) error }) (Schedule, error) {
+	sc := Schedule{}
+	var at, next, last sql.NullTime
+	err := row.Scan(
+		&sc.ID,
+		&sc.Name,
+		&sc.PBCID,
+		&sc.Cron,
+		&at,
+		&sc.TimeZone,
+		&sc.Action,
+		&sc.VideoID,
+		&sc.StartSeconds,
+		&sc.EndSeconds,
+		&sc.Loop,
+		&sc.PlaylistID,
+		&sc.Missed,
+		&sc.Enabled,
+		&next,
+		&last,
+		&sc.LastError,
+		&sc.CreatedAt,
+	)
+
+	if at.Valid {
+		sc.At = &at.Time
+	}
+
+	if next.Valid {
+		sc.NextRun = &next.Time
+	}
+
+	if last.Valid {
+		sc.LastRun = &last.Time
+	}
+
+	return sc, err
+}
+
+// ScheduleCreate saves a new schedule and returns its ID.
+func (db *SqliteDB) ScheduleCreate(sc Schedule) (int64, error) {
+	if sc.PBCID == "" {
+		return 0, fmt.Errorf("SqliteDB.ScheduleCreate: pbcID - %w", ErrParamEmpty)
+	}
+
+	query := `INSERT INTO ` + tb_schedules + ` (name, pbc_id, cron, run_at, time_zone, action,
+		video_id, start_seconds, end_seconds, loop, playlist_id, missed, enabled, next_run, last_run,
+		last_error, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	res, err := db.Exec(query, sc.Name, sc.PBCID, sc.Cron, sc.At, sc.TimeZone, sc.Action,
+		sc.VideoID, sc.StartSeconds, sc.EndSeconds, sc.Loop, sc.PlaylistID, sc.Missed, sc.Enabled,
+		sc.NextRun, sc.LastRun, sc.LastError, sc.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("SqliteDB.ScheduleCreate: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("SqliteDB.ScheduleCreate: %w", err)
+	}
+
+	return id, nil
+}
+
+// ScheduleList retrieves every schedule in the order they were created.
+func (db *SqliteDB) ScheduleList() ([]Schedule, error) {
+	rows, err := db.Query(`SELECT ` + scheduleColumns + ` FROM ` + tb_schedules + ` ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("SqliteDB.ScheduleList: %w", err)
+	}
+	defer rows.Close()
+
+	var list []Schedule
+	for rows.Next() {
+		sc, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("SqliteDB.ScheduleList: %w", err)
+		}
+
+		list = append(list, sc)
+	}
+
+	return list, rows.Err()
+}
+
+// ScheduleUpdate saves every field of the schedule except its creation time.
+func (db *SqliteDB) ScheduleUpdate(sc Schedule) error {
+	if sc.ID < 1 {
+		return fmt.Errorf("SqliteDB.ScheduleUpdate: %w", ErrInvalidID)
+	}
+
+	query := `UPDATE ` + tb_schedules + ` SET name = ?, pbc_id = ?, cron = ?, run_at = ?,
+		time_zone = ?, action = ?, video_id = ?, start_seconds = ?, end_seconds = ?, loop = ?,
+		playlist_id = ?, missed = ?, enabled = ?, next_run = ?, last_run = ?, last_error = ?
+		WHERE id = ?`
+	_, err := db.Exec(query, sc.Name, sc.PBCID, sc.Cron, sc.At, sc.TimeZone, sc.Action, sc.VideoID,
+		sc.StartSeconds, sc.EndSeconds, sc.Loop, sc.PlaylistID, sc.Missed, sc.Enabled, sc.NextRun,
+		sc.LastRun, sc.LastError, sc.ID)
+	if err != nil {
+		return fmt.Errorf("SqliteDB.ScheduleUpdate: %w", err)
+	}
+
+	return nil
+}
+
+// ScheduleDelete deletes the schedule.
+func (db *SqliteDB) ScheduleDelete(id int64) error {
+	if _, err := db.Exec(`DELETE FROM `+tb_schedules+` WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("SqliteDB.ScheduleDelete: %w", err)
+	}
+
+	return nil
+}
+
+// ############################################################################################## //
+// ###################################         Filters         ################################## //
+// ############################################################################################## //
+
+// FilterRuleCreate saves a new filter rule and returns its ID.
+func (db *SqliteDB) FilterRuleCreate(r FilterRule) (int64, error) {
+	if r.PBCID == "" {
+		return 0, fmt.Errorf("SqliteDB.FilterRuleCreate: pbcID - %w", ErrParamEmpty)
+	}
+
+	query := `INSERT INTO ` + tb_filter_rules + ` (pbc_id, kind, action, value, created_at)
+		VALUES (?, ?, ?, ?, ?)`
+	res, err := db.Exec(query, r.PBCID, r.Kind, r.Action, r.Value, r.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("SqliteDB.FilterRuleCreate: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("SqliteDB.FilterRuleCreate: %w", err)
+	}
+
+	return id, nil
+}
+
+// FilterRuleList retrieves every filter rule in the order they were created.
+func (db *SqliteDB) FilterRuleList() ([]FilterRule, error) {
+	query := `SELECT id, pbc_id, kind, action, value, created_at FROM ` + tb_filter_rules + ` ORDER BY id`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("SqliteDB.FilterRuleList: %w", err)
+	}
+	defer rows.Close()
+
+	var list []FilterRule
+	for rows.Next() {
+		r := FilterRule{}
+		if err := rows.Scan(&r.ID, &r.PBCID, &r.Kind, &r.Action, &r.Value, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("SqliteDB.FilterRuleList: %w", err)
+		}
+
+		list = append(list, r)
+	}
+
+	return list, rows.Err()
+}
+
+// FilterRuleDelete deletes the filter rule.
+func (db *SqliteDB) FilterRuleDelete(id int64) error {
+	if _, err := db.Exec(`DELETE FROM `+tb_filter_rules+` WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("SqliteDB.FilterRuleDelete: %w", err)
+	}
+
+	return nil
+}
+
+// BlockedCreate records a video which a filter rule kept out of a queue.
+func (db *SqliteDB) BlockedCreate(b BlockedVideo) error {
+	if b.PBCID == "" {
+		return fmt.Errorf("SqliteDB.BlockedCreate: pbcID - %w", ErrParamEmpty)
+	}
+
+	query := `INSERT INTO ` + tb_blocked_videos + ` (pbc_id, video_id, title, author_name,
+		thumbnail_url, added_by, reason, blocked_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := db.Exec(query, b.PBCID, b.VideoID, b.Title, b.AuthorName, b.ThumbnailURL, b.AddedBy,
+		b.Reason, b.BlockedAt)
+	if err != nil {
+		return fmt.Errorf("SqliteDB.BlockedCreate: %w", err)
+	}
+
+	return nil
+}
+
+// BlockedList fills page with the playback client's blocked videos, newest first. The page must
+// come from NewBlockedPage.
+func (db *SqliteDB) BlockedList(pbcID string, page BlockedPage) (BlockedPage, error) {
+	if pbcID == "" {
+		return page, fmt.Errorf("SqliteDB.BlockedList: pbcID - %w", ErrParamEmpty)
+	}
+
+	row, err := db.QueryRow(`SELECT COUNT(*) FROM `+tb_blocked_videos+` WHERE pbc_id = ?`, pbcID)
+	if err != nil {
+		return page, fmt.Errorf("SqliteDB.BlockedList: %w", err)
+	}
+
+	if err := row.Scan(&page.Total); err != nil {
+		return page, fmt.Errorf("SqliteDB.BlockedList: %w", err)
+	}
+
+	query := `SELECT id, pbc_id, video_id, title, author_name, thumbnail_url, added_by, reason,
+		blocked_at FROM ` + tb_blocked_videos + `
+		WHERE pbc_id = ? ORDER BY blocked_at DESC, id DESC LIMIT ? OFFSET ?`
+	rows, err := db.Query(query, pbcID, page.PerPage, (page.Page-1)*page.PerPage)
+	if err != nil {
+		return page, fmt.Errorf("SqliteDB.BlockedList: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		b := BlockedVideo{}
+		err := rows.Scan(
+			&b.ID,
+			&b.PBCID,
+			&b.VideoID,
+			&b.Title,
+			&b.AuthorName,
+			&b.ThumbnailURL,
+			&b.AddedBy,
+			&b.Reason,
+			&b.BlockedAt,
+		)
+		if err != nil {
+			return page, fmt.Errorf("SqliteDB.BlockedList: %w", err)
+		}
+
+		page.Entries = append(page.Entries, b)
+	}
+
+	return page, rows.Err()
+}
+
+// ############################################################################################## //
+// ###################################    Playback Windows     ################################## //
+// ############################################################################################## //
+
+// WindowCreate saves a new playback window and returns its ID.
+func (db *SqliteDB) WindowCreate(w PlaybackWindow) (int64, error) {
+	if w.PBCID == "" {
+		return 0, fmt.Errorf("SqliteDB.WindowCreate: pbcID - %w", ErrParamEmpty)
+	}
+
+	query := `INSERT INTO ` + tb_windows + ` (pbc_id, days, start_time, end_time, time_zone,
+		standby, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`
+	res, err := db.Exec(query, w.PBCID, w.Days, w.Start, w.End, w.TimeZone, w.Standby, w.CreatedAt)
+	if err != nil {
+		return 0, fmt.Errorf("SqliteDB.WindowCreate: %w", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("SqliteDB.WindowCreate: %w", err)
+	}
+
+	return id, nil
+}
+
+// WindowList retrieves every playback window in the order they were created.
+func (db *SqliteDB) WindowList() ([]PlaybackWindow, error) {
+	query := `SELECT id, pbc_id, days, start_time, end_time, time_zone, standby, created_at
+		FROM ` + tb_windows + ` ORDER BY id`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("SqliteDB.WindowList: %w", err)
+	}
+	defer rows.Close()
+
+	var list []PlaybackWindow
+	for rows.Next() {
+		w := PlaybackWindow{}
+		err := rows.Scan(&w.ID, &w.PBCID, &w.Days, &w.Start, &w.End, &w.TimeZone, &w.Standby, &w.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("SqliteDB.WindowList: %w", err)
+		}
+
+		list = append(list, w)
+	}
+
+	return list, rows.Err()
+}
+
+// WindowDelete deletes the playback window.
+func (db *SqliteDB) WindowDelete(id int64) error {
+	if _, err := db.Exec(`DELETE FROM `+tb_windows+` WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("SqliteDB.WindowDelete: %w", err)
+	}
+
+	return nil
+}
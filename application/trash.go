@@ -0,0 +1,122 @@
+package application
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// DefaultTrashRetention is how long removed videos can be restored.
+const DefaultTrashRetention = 24 * time.Hour
+
+const (
+	TrashRemoved = "removed"
+	TrashCleared = "cleared"
+)
+
+var ErrTrashEmpty = fmt.Errorf("nothing to undo")
+
+// TrashEntry is a video which was removed or cleared from a playlist and can still be restored.
+// Entries removed by the same request share a Batch. Position is the video's index in the playlist
+// when it was removed.
+type TrashEntry struct {
+	ID         int64  `json:"id"`
+	Batch      int64  `json:"batch"`
+	PBCID      string `json:"pbc_id"`
+	PlaylistID string `json:"playlist_id"`
+	Position   int    `json:"position"`
+	VideoDetails
+	Reason    string    `json:"reason"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// SetTrashRetention sets how long removed videos are kept in the trash. A retention of 0 uses
+// DefaultTrashRetention.
+func (pls *Playlists) SetTrashRetention(d time.Duration) {
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	if d <= 0 {
+		d = DefaultTrashRetention
+	}
+
+	pls.trashRetention = d
+}
+
+// Trash returns the playback client's restorable videos, newest first.
+func (pls *Playlists) Trash(pbc PlaybackClient) ([]TrashEntry, error) {
+	pls.mu.RLock()
+	since := time.Now().Add(-pls.trashRetention)
+	pls.mu.RUnlock()
+
+	entries, err := pls.db.TrashList(pbc.ID, since)
+	if err != nil {
+		return nil, fmt.Errorf("Playlists.Trash: %w", err)
+	}
+
+	return entries, nil
+}
+
+// trash moves the items at the given indexes of the playlist into the playback client's trash. The
+// caller must hold the write lock.
+func (pls *Playlists) trash(pbc PlaybackClient, id string, cur Playlist, indexes []int, reason string) error {
+	now := time.Now()
+	entries := make([]TrashEntry, 0, len(indexes))
+	for _, i := range indexes {
+		entries = append(entries, TrashEntry{
+			PBCID:        pbc.ID,
+			PlaylistID:   id,
+			Position:     i,
+			VideoDetails: cur[i],
+			Reason:       reason,
+			DeletedAt:    now,
+		})
+	}
+
+	if err := pls.db.TrashAdd(entries); err != nil {
+		return fmt.Errorf("Playlists.trash: %w", err)
+	}
+
+	if _, err := pls.db.TrashPurge(now.Add(-pls.trashRetention)); err != nil {
+		return fmt.Errorf("Playlists.trash: %w", err)
+	}
+
+	return nil
+}
+
+// Undo restores the videos from the playback client's most recent remove or clear which is still
+// within the retention window. The videos go back to the playlist they were removed from, at their
+// old positions and with their old item IDs, even if the playback client has since switched to
+// another playlist. Calling Undo again restores the remove or clear before that. ErrTrashEmpty is
+// returned if there is nothing to restore.
+func (pls *Playlists) Undo(pbc PlaybackClient) ([]TrashEntry, error) {
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	entries, err := pls.db.TrashLatest(pbc.ID, time.Now().Add(-pls.trashRetention))
+	if err != nil {
+		return nil, fmt.Errorf("Playlists.Undo: %w", err)
+	}
+
+	if len(entries) == 0 {
+		return nil, ErrTrashEmpty
+	}
+
+	id := entries[0].PlaylistID
+	if _, ok := pls.infos[id]; !ok {
+		return nil, ErrUnknownPlaylist
+	}
+
+	// Entries are in position order so inserting each at its old index rebuilds the old order.
+	pl := slices.Clone(pls.lists[id])
+	for _, e := range entries {
+		pl = slices.Insert(pl, min(e.Position, len(pl)), e.VideoDetails)
+	}
+
+	if err := pls.db.TrashRestore(entries[0].Batch, id, pl); err != nil {
+		return nil, fmt.Errorf("Playlists.Undo: %w", err)
+	}
+
+	pls.set(id, pl)
+	return entries, nil
+}
@@ -0,0 +1,116 @@
+package application
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// ParseVote reads a vote from a query string value. Up is 1, down is -1.
+func ParseVote(s string) (int, error) {
+	switch s {
+	case "up", "1", "+1":
+		return 1, nil
+	case "down", "-1":
+		return -1, nil
+	}
+
+	return 0, fmt.Errorf("invalid vote: %q", s)
+}
+
+// Score is the item's upvotes less its downvotes.
+func (d VideoDetails) Score() int {
+	return d.Upvotes - d.Downvotes
+}
+
+// voteCompare orders items by score, highest first, with ties going to the item added first.
+func voteCompare(a, b VideoDetails) int {
+	if c := cmp.Compare(b.Score(), a.Score()); c != 0 {
+		return c
+	}
+
+	if c := a.AddedAt.Compare(b.AddedAt); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(a.ItemID, b.ItemID)
+}
+
+// voteIndex returns where a new item goes so the items from start on stay in vote order. The item
+// goes after every item it ties with.
+func (pl Playlist) voteIndex(d VideoDetails, start int) int {
+	for i := start; i < len(pl); i++ {
+		if voteCompare(pl[i], d) > 0 {
+			return i
+		}
+	}
+
+	return max(start, len(pl))
+}
+
+// voteOrder returns a copy of the playlist with the items from start on sorted by vote.
+func (pl Playlist) voteOrder(start int) Playlist {
+	sorted := slices.Clone(pl)
+	if start < len(sorted) {
+		slices.SortStableFunc(sorted[start:], voteCompare)
+	}
+
+	return sorted
+}
+
+// voteStart returns the index vote ordering starts from in the playback client's playlist. The
+// video the playback client is playing keeps its place at the front. The caller must hold the lock.
+func (pls *Playlists) voteStart(pbc PlaybackClient, pl Playlist) int {
+	if np, ok := pls.playing[pbc.ID]; ok && len(pl) > 0 && pl[0].ItemID == np.ItemID {
+		return 1
+	}
+
+	return 0
+}
+
+// Vote records the voter's vote on the item in the playback client's playlist and returns the
+// item with its new counts. A vote of 1 is an upvote, -1 a downvote, and 0 takes back the voter's
+// vote. Each voter has one vote per item and voting again replaces it. In vote-ordered mode the
+// playlist is sorted again after the vote.
+func (pls *Playlists) Vote(pbc PlaybackClient, itemID int64, voter string, vote int) (VideoDetails, error) {
+	if vote < -1 || vote > 1 {
+		return VideoDetails{}, fmt.Errorf("invalid vote: %d", vote)
+	}
+
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	id, cur := pls.active(pbc)
+	if id == "" {
+		return VideoDetails{}, ErrNoActivePlaylist
+	}
+
+	i := cur.indexOf(itemID)
+	if i < 0 {
+		return VideoDetails{}, ErrItemNotFound
+	}
+
+	up, down, err := pls.db.VoteSet(itemID, voter, vote)
+	if err != nil {
+		return VideoDetails{}, fmt.Errorf("Playlists.Vote: %w", err)
+	}
+
+	pl := slices.Clone(cur)
+	pl[i].Upvotes = up
+	pl[i].Downvotes = down
+	d := pl[i]
+
+	if pls.settingsFor(pbc.ID).Mode == ModeVoteOrdered {
+		sorted := pl.voteOrder(pls.voteStart(pbc, pl))
+		if err := pls.db.PlaylistItemReorder(id, sorted); err != nil {
+			// The vote is already saved, so keep the new counts in the order that is still stored.
+			pls.set(id, pl)
+			return d, fmt.Errorf("Playlists.Vote: %w", err)
+		}
+
+		pl = sorted
+	}
+
+	pls.set(id, pl)
+	return d, nil
+}
@@ -0,0 +1,134 @@
+package application
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVoteHandlerOneVotePerClient(t *testing.T) {
+	pls, pbc := newTestPlaylists(t)
+	d, err := pls.Add(pbc, VideoURL{VideoID: testVideoID(1)}, Submitter{Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewHTTPServer(pls.Logger, "", 0, "", "", pls, nil, nil, nil, nil, pls.db)
+	s.AddRoutes()
+
+	vote := func(addr, by string) {
+		t.Helper()
+
+		w := httptest.NewRecorder()
+		u := fmt.Sprintf("/playlists/%s/items/%d/vote?vote=up&by=%s", pbc.ID, d.ItemID, by)
+		r := httptest.NewRequest(http.MethodPut, u, nil)
+		r.RemoteAddr = addr
+		s.Mux.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d: %s", w.Code, w.Body)
+		}
+	}
+
+	// Changing the nickname doesn't give the client another vote.
+	vote("192.0.2.1:1234", "alice")
+	vote("192.0.2.1:1234", "bob")
+	vote("192.0.2.2:1234", "alice")
+
+	pl, _ := pls.Get(pbc)
+	if pl[0].Upvotes != 2 {
+		t.Fatalf("got %d upvotes, want 2", pl[0].Upvotes)
+	}
+}
+
+func TestUndoRestoresVotes(t *testing.T) {
+	pls, pbc := newTestPlaylists(t)
+	if _, err := pls.SetMode(pbc, ModeVoteOrdered); err != nil {
+		t.Fatal(err)
+	}
+
+	var items []VideoDetails
+	for i := range 3 {
+		d, err := pls.Add(pbc, VideoURL{VideoID: testVideoID(i)}, Submitter{Name: "test"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		items = append(items, d)
+	}
+
+	// The last video added is voted to the front.
+	for _, voter := range []string{"192.0.2.1", "192.0.2.2"} {
+		if _, err := pls.Vote(pbc, items[2].ItemID, voter, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := pls.Remove(pbc, items[2].ItemID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pls.Undo(pbc); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+
+	pl, _ := pls.Get(pbc)
+	if pl[0].ItemID != items[2].ItemID || pl[0].Upvotes != 2 {
+		t.Fatalf("got %+v first, want item %d with 2 upvotes", pl[0], items[2].ItemID)
+	}
+
+	checkPersisted(t, pls, pbc)
+	stored, err := pls.db.PlaylistItemList(pbc.PlaylistID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored[0].Upvotes != 2 {
+		t.Fatalf("got %d stored upvotes, want 2", stored[0].Upvotes)
+	}
+
+	// A vote after the undo replaces the restored one rather than adding to it.
+	d, err := pls.Vote(pbc, items[2].ItemID, "192.0.2.1", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Upvotes != 1 || d.Downvotes != 1 {
+		t.Fatalf("got %d up and %d down, want 1 and 1", d.Upvotes, d.Downvotes)
+	}
+}
+
+func TestTrashPurgeRemovesVotes(t *testing.T) {
+	pls, pbc := newTestPlaylists(t)
+	d, err := pls.Add(pbc, VideoURL{VideoID: testVideoID(1)}, Submitter{Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pls.Vote(pbc, d.ItemID, "192.0.2.1", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pls.Remove(pbc, d.ItemID); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := pls.db.TrashPurge(time.Now().Add(time.Hour)); err != nil || n != 1 {
+		t.Fatalf("TrashPurge: got %d, %v, want 1", n, err)
+	}
+
+	row, err := pls.db.QueryRow(`SELECT COUNT(*) FROM ` + tb_trash_votes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	if err := row.Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 0 {
+		t.Fatalf("%d votes left after the trash was purged", n)
+	}
+}
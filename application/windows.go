@@ -0,0 +1,283 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+)
+
+// windowCheckInterval is how often WindowWatcher looks for playback windows which have closed.
+const windowCheckInterval = 30 * time.Second
+
+var (
+	ErrOutsideWindow = fmt.Errorf("outside playback window")
+	ErrUnknownWindow = fmt.Errorf("playback window not found")
+)
+
+// PlaybackWindow is a time of day a playback client may hand out videos, such as 07:00 to 21:00.
+// A playback client with no windows can always play. Days takes the day of week field of a cron
+// expression, such as mon-fri, and a window whose end is before its start runs past midnight into
+// the next day. If Standby is set the playback client's TV is put in standby over CEC when the
+// window closes.
+type PlaybackWindow struct {
+	ID        int64     `json:"id"`
+	PBCID     string    `json:"pbc_id"`
+	Days      string    `json:"days"`
+	Start     string    `json:"start"`
+	End       string    `json:"end"`
+	TimeZone  string    `json:"time_zone"`
+	Standby   bool      `json:"standby"`
+	CreatedAt time.Time `json:"created_at"`
+
+	days  uint64
+	start int // minutes after midnight
+	end   int
+	loc   *time.Location
+}
+
+// NewPlaybackWindow validates the window and returns a new PlaybackWindow. Days defaults to every
+// day and the time zone to the server's.
+func NewPlaybackWindow(pbcID, days, start, end, timeZone string, standby bool) (PlaybackWindow, error) {
+	if days == "" {
+		days = "*"
+	}
+
+	if timeZone == "" {
+		timeZone = "Local"
+	}
+
+	w := PlaybackWindow{
+		PBCID:     pbcID,
+		Days:      days,
+		Start:     start,
+		End:       end,
+		TimeZone:  timeZone,
+		Standby:   standby,
+		CreatedAt: time.Now(),
+	}
+
+	return w, w.prepare()
+}
+
+// prepare parses the window's days, times, and time zone.
+func (w *PlaybackWindow) prepare() error {
+	var err error
+	if w.days, err = parseCronWeekdays(w.Days); err != nil {
+		return fmt.Errorf("invalid days: %w", err)
+	}
+
+	if w.start, err = parseClock(w.Start); err != nil {
+		return fmt.Errorf("invalid start: %w", err)
+	}
+
+	if w.end, err = parseClock(w.End); err != nil {
+		return fmt.Errorf("invalid end: %w", err)
+	}
+
+	if w.start == w.end {
+		return fmt.Errorf("invalid window: start and end are the same")
+	}
+
+	if w.loc, err = time.LoadLocation(w.TimeZone); err != nil {
+		return fmt.Errorf("invalid time zone: %q", w.TimeZone)
+	}
+
+	return nil
+}
+
+// parseClock returns the minutes after midnight of a time of day such as 07:00.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time such as 07:00", s)
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func (w PlaybackWindow) onDay(day time.Weekday) bool {
+	return w.days&(1<<int(day)) != 0
+}
+
+// contains reports whether t falls inside the window.
+func (w PlaybackWindow) contains(t time.Time) bool {
+	t = t.In(w.loc)
+	m := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return w.onDay(t.Weekday()) && m >= w.start && m < w.end
+	}
+
+	// The window started yesterday and runs past midnight.
+	yesterday := (t.Weekday() + 6) % 7
+	return (w.onDay(t.Weekday()) && m >= w.start) || (w.onDay(yesterday) && m < w.end)
+}
+
+// nextOpen returns the first time after t the window opens.
+func (w PlaybackWindow) nextOpen(t time.Time) time.Time {
+	t = t.In(w.loc)
+	for d := 0; d <= 7; d++ {
+		o := time.Date(t.Year(), t.Month(), t.Day()+d, w.start/60, w.start%60, 0, 0, w.loc)
+		if o.After(t) && w.onDay(o.Weekday()) {
+			return o
+		}
+	}
+
+	return time.Time{}
+}
+
+// WindowStatus is whether a playback client is inside one of its playback windows.
+type WindowStatus struct {
+	Open      bool             `json:"open"`
+	Reason    string           `json:"reason,omitempty"`
+	ResumesAt *time.Time       `json:"resumes_at,omitempty"`
+	Windows   []PlaybackWindow `json:"windows"`
+}
+
+// windowOpen reports whether the playback client may play at t. If it may not, the time its next
+// window opens is returned as well. The caller must hold the lock.
+func (pls *Playlists) windowOpen(pbcID string, t time.Time) (bool, time.Time) {
+	var resume time.Time
+	for _, w := range pls.windows[pbcID] {
+		if w.contains(t) {
+			return true, time.Time{}
+		}
+
+		if o := w.nextOpen(t); resume.IsZero() || o.Before(resume) {
+			resume = o
+		}
+	}
+
+	return len(pls.windows[pbcID]) == 0, resume
+}
+
+// outsideWindow returns an error wrapping ErrOutsideWindow which says when playback resumes.
+func outsideWindow(resume time.Time) error {
+	return fmt.Errorf("%w: playback resumes %s", ErrOutsideWindow, resume.Format("Mon 15:04 MST"))
+}
+
+// Windows returns the playback client's playback windows and whether it is inside one now.
+func (pls *Playlists) Windows(pbc PlaybackClient) WindowStatus {
+	pls.mu.RLock()
+	defer pls.mu.RUnlock()
+
+	st := WindowStatus{Windows: slices.Clone(pls.windows[pbc.ID])}
+	if st.Windows == nil {
+		st.Windows = []PlaybackWindow{}
+	}
+
+	var resume time.Time
+	if st.Open, resume = pls.windowOpen(pbc.ID, time.Now()); !st.Open {
+		st.Reason = outsideWindow(resume).Error()
+		st.ResumesAt = &resume
+	}
+
+	return st
+}
+
+// AddWindow saves a new playback window for the playback client. The window must come from
+// NewPlaybackWindow.
+func (pls *Playlists) AddWindow(pbc PlaybackClient, w PlaybackWindow) (PlaybackWindow, error) {
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	w.PBCID = pbc.ID
+	id, err := pls.db.WindowCreate(w)
+	if err != nil {
+		return w, fmt.Errorf("Playlists.AddWindow: %w", err)
+	}
+
+	w.ID = id
+	pls.windows[pbc.ID] = append(pls.windows[pbc.ID], w)
+	return w, nil
+}
+
+// DeleteWindow deletes one of the playback client's playback windows.
+func (pls *Playlists) DeleteWindow(pbc PlaybackClient, id int64) error {
+	pls.mu.Lock()
+	defer pls.mu.Unlock()
+
+	windows := pls.windows[pbc.ID]
+	i := slices.IndexFunc(windows, func(w PlaybackWindow) bool { return w.ID == id })
+	if i < 0 {
+		return ErrUnknownWindow
+	}
+
+	if err := pls.db.WindowDelete(id); err != nil {
+		return fmt.Errorf("Playlists.DeleteWindow: %w", err)
+	}
+
+	pls.windows[pbc.ID] = slices.Delete(slices.Clone(windows), i, i+1)
+	return nil
+}
+
+// standbyDue returns the playback clients which were inside a window with Standby set at since and
+// are outside all of their windows at now.
+func (pls *Playlists) standbyDue(since, now time.Time) []string {
+	pls.mu.RLock()
+	defer pls.mu.RUnlock()
+
+	var due []string
+	for pbcID, windows := range pls.windows {
+		if open, _ := pls.windowOpen(pbcID, now); open {
+			continue
+		}
+
+		if slices.ContainsFunc(windows, func(w PlaybackWindow) bool { return w.Standby && w.contains(since) }) {
+			due = append(due, pbcID)
+		}
+	}
+
+	return due
+}
+
+// WindowWatcher puts a playback client's TV in standby over CEC when one of its playback windows
+// with Standby set closes.
+type WindowWatcher struct {
+	Logger *log.Logger
+
+	db  *SqliteDB
+	pls *Playlists
+}
+
+// NewWindowWatcher creates a new WindowWatcher for the playback clients in pls.
+func NewWindowWatcher(logger *log.Logger, db *SqliteDB, pls *Playlists) *WindowWatcher {
+	return &WindowWatcher{Logger: logger, db: db, pls: pls}
+}
+
+// Run checks for closed windows until ctx is done. Windows which closed while ytqueuer was stopped
+// are ignored.
+func (ww *WindowWatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(windowCheckInterval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, pbcID := range ww.pls.standbyDue(last, now) {
+				ww.standby(pbcID)
+			}
+
+			last = now
+		}
+	}
+}
+
+func (ww *WindowWatcher) standby(pbcID string) {
+	cec, err := ww.db.CECGet(pbcID)
+	if err != nil {
+		ww.Logger.Printf("WindowWatcher: %s: no CEC config: %v\n", pbcID, err)
+		return
+	}
+
+	if err := cec.PowerOff(); err != nil {
+		ww.Logger.Printf("WindowWatcher: %s: %v\n", pbcID, err)
+		return
+	}
+
+	ww.Logger.Printf("WindowWatcher: playback window closed, put %s in standby\n", cec.Alias)
+}
@@ -0,0 +1,187 @@
+package application
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// tsFormat matches YouTube timestamps such as 90, 90s, 1m30s, or 1h2m3s.
+	tsFormat = regexp.MustCompile(`^(?:(\d+)h)?(?:(\d+)m)?(?:(\d+)s?)?$`)
+
+	ErrInvalidURL = fmt.Errorf("not a youtube video url")
+)
+
+// VideoURL is a YouTube video link broken down into what the queue needs. EndSeconds is 0 when
+// the video should play to the end. If Loop is set the player jumps back to StartSeconds instead
+// of moving on to the next video.
+type VideoURL struct {
+	VideoID      string `json:"video_id"`
+	StartSeconds int    `json:"start_seconds"`
+	EndSeconds   int    `json:"end_seconds"`
+	Loop         bool   `json:"loop"`
+}
+
+// Validate checks the video ID and that the clip range is sensible.
+func (v VideoURL) Validate() error {
+	if err := validateVideoID(v.VideoID); err != nil {
+		return err
+	}
+
+	if v.StartSeconds < 0 {
+		return fmt.Errorf("invalid start time: %d", v.StartSeconds)
+	}
+
+	if v.EndSeconds < 0 || (v.EndSeconds != 0 && v.EndSeconds <= v.StartSeconds) {
+		return fmt.Errorf("end time must be after the start time: %d", v.EndSeconds)
+	}
+
+	return nil
+}
+
+// ParseVideoURL normalizes any of the common YouTube link formats into a video ID and start time.
+// Supported formats include:
+//
+//	https://www.youtube.com/watch?v=<id>&t=1m30s
+//	https://m.youtube.com/watch?v=<id>
+//	https://music.youtube.com/watch?v=<id>
+//	https://youtu.be/<id>?t=90
+//	https://www.youtube.com/shorts/<id>
+//	https://www.youtube.com/live/<id>
+//	https://www.youtube.com/embed/<id>?start=90&end=120&loop=1
+//
+// The scheme may be left off and a bare video ID is also accepted. The start time is read from
+// the 't' or 'start' query parameters or a '#t=' fragment, the end time from the 'end' query
+// parameter, and looping from the 'loop' query parameter.
+func ParseVideoURL(raw string) (VideoURL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return VideoURL{}, fmt.Errorf("ParseVideoURL: url - %w", ErrParamEmpty)
+	}
+
+	if vidFormat.MatchString(raw) {
+		return VideoURL{VideoID: raw}, nil
+	}
+
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return VideoURL{}, fmt.Errorf("ParseVideoURL: %w", err)
+	}
+
+	vid, err := videoIDFromURL(u)
+	if err != nil {
+		return VideoURL{}, fmt.Errorf("ParseVideoURL: %w", err)
+	}
+
+	start, err := startFromURL(u)
+	if err != nil {
+		return VideoURL{}, fmt.Errorf("ParseVideoURL: %w", err)
+	}
+
+	v := VideoURL{VideoID: vid, StartSeconds: start}
+	if end := u.Query().Get("end"); end != "" {
+		if v.EndSeconds, err = ParseTimestamp(end); err != nil {
+			return VideoURL{}, fmt.Errorf("ParseVideoURL: %w", err)
+		}
+	}
+
+	if loop := u.Query().Get("loop"); loop != "" {
+		if v.Loop, err = strconv.ParseBool(loop); err != nil {
+			return VideoURL{}, fmt.Errorf("ParseVideoURL: invalid loop: %s", loop)
+		}
+	}
+
+	if err := v.Validate(); err != nil {
+		return VideoURL{}, fmt.Errorf("ParseVideoURL: %w", err)
+	}
+
+	return v, nil
+}
+
+// videoIDFromURL pulls the video ID out of the url's path or query based on the host.
+func videoIDFromURL(u *url.URL) (string, error) {
+	host, ok := youtubeHost(u)
+	if !ok {
+		return "", fmt.Errorf("%w: unknown host %s", ErrInvalidURL, u.Host)
+	}
+
+	path := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if host == "youtu.be" {
+		return path[0], nil
+	}
+
+	switch path[0] {
+	case "watch":
+		if vid := u.Query().Get("v"); vid != "" {
+			return vid, nil
+		}
+	case "shorts", "live", "embed", "v":
+		if len(path) > 1 {
+			return path[1], nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: %s", ErrInvalidURL, u.String())
+}
+
+// youtubeHost returns the url's host without any 'www.' prefix and whether it is a YouTube host.
+func youtubeHost(u *url.URL) (string, bool) {
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	switch host {
+	case "youtu.be", "youtube.com", "m.youtube.com", "music.youtube.com", "youtube-nocookie.com":
+		return host, true
+	}
+
+	return host, false
+}
+
+// startFromURL returns the start time from the 't' or 'start' query parameters, or a 't' fragment.
+// It returns 0 if there is no start time.
+func startFromURL(u *url.URL) (int, error) {
+	q := u.Query()
+	ts := q.Get("t")
+	if ts == "" {
+		ts = q.Get("start")
+	}
+
+	if ts == "" && strings.HasPrefix(u.Fragment, "t=") {
+		ts = strings.TrimPrefix(u.Fragment, "t=")
+	}
+
+	if ts == "" {
+		return 0, nil
+	}
+
+	return ParseTimestamp(ts)
+}
+
+// ParseTimestamp converts a YouTube timestamp such as 90, 90s, 1m30s, or 1h2m3s into seconds.
+func ParseTimestamp(ts string) (int, error) {
+	m := tsFormat.FindStringSubmatch(ts)
+	if ts == "" || m == nil {
+		return 0, fmt.Errorf("invalid timestamp: %s", ts)
+	}
+
+	secs := 0
+	for i, mul := range []int{3600, 60, 1} {
+		if m[i+1] == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp: %s", ts)
+		}
+
+		secs += n * mul
+	}
+
+	return secs, nil
+}
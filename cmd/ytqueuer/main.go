@@ -28,7 +28,7 @@ const lock_file = "ytqueuer.pid"
 var (
 	appName    = "ytqueuer"
 	regAppName = regexp.MustCompile(appName)
-	help       = fmt.Sprintf(`Usage: %s [options] [help|version|start|stop]
+	help       = fmt.Sprintf(`Usage: %s [options] [help|version|start|stop|migrate-status|migrate-dry-run]
 
 Options:
   -h, --help     Show this help message
@@ -39,6 +39,8 @@ Commands:
   version  Show the version number
   start    Start the ytqueuer server
   stop     Stop the currently running ytqueuer server
+  migrate-status   Show which database migrations have been applied
+  migrate-dry-run  Apply pending database migrations and roll them back
 
 Examples:
   ytqueuer start
@@ -54,11 +56,17 @@ func start(
 	port int,
 	certFile string,
 	keyFile string,
-	pls ytqueuer.Playlists,
+	pls *ytqueuer.Playlists,
+	events *ytqueuer.Broker,
+	schedules *ytqueuer.Scheduler,
+	metadata *ytqueuer.MetadataCache,
+	fetcher ytqueuer.PlaylistFetcher,
 	db *ytqueuer.SqliteDB,
 ) error {
 	// queue := ytqueuer.NewQueue()
-	server := ytqueuer.NewHTTPServer(logger, addr, port, certFile, keyFile, pls, db)
+	server := ytqueuer.NewHTTPServer(
+		logger, addr, port, certFile, keyFile, pls, events, schedules, metadata, fetcher, db,
+	)
 	server.AddRoutes()
 
 	srvErr := make(chan error)
@@ -103,7 +111,8 @@ func stop(logger *log.Logger) {
 }
 
 func main() {
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	logger := log.New(os.Stdout, "ytqueuer: ", log.LstdFlags)
 
 	// Parse args. Right now we only look for stop. If stop is passed the program will exit.
@@ -121,9 +130,6 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Create a playlist cache.
-	pls := ytqueuer.NewPlaylists()
-
 	// Create a new sqlite3 database.
 	db, err := initDB()
 	if err != nil {
@@ -132,8 +138,66 @@ func main() {
 	}
 	defer db.Close()
 
-	// Load our client and playlist data from the database.
-	pls.LoadFromDB(db)
+	// Video metadata is looked up in the background and cached in the database.
+	// YTQUEUER_OEMBED_URL can point at a local oEmbed stub instead of YouTube and
+	// YTQUEUER_METADATA_TTL sets how long cached metadata is kept, e.g. 720h. oEmbed
+	// has no video durations, so they come from the YouTube Data API when
+	// YTQUEUER_YOUTUBE_API_KEY is set and from the players otherwise.
+	ttl, err := envDuration("YTQUEUER_METADATA_TTL")
+	if err != nil {
+		log.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var provider ytqueuer.MetadataProvider = ytqueuer.NewOEmbedProvider(os.Getenv("YTQUEUER_OEMBED_URL"))
+	if key := os.Getenv("YTQUEUER_YOUTUBE_API_KEY"); key != "" {
+		provider = ytqueuer.NewDurationMetadataProvider(
+			provider,
+			ytqueuer.NewYouTubeDurationProvider(key, os.Getenv("YTQUEUER_YOUTUBE_API_URL")),
+		)
+	}
+
+	events := ytqueuer.NewBroker()
+	cache := ytqueuer.NewMetadataCache(logger, db, provider, ttl)
+	metadata := ytqueuer.NewMetadataResolver(logger, cache)
+
+	// Create the playlist store and load our client and playlist data from the database.
+	pls := ytqueuer.NewPlaylists(logger, db, metadata, events)
+	if err := pls.LoadFromDB(); err != nil {
+		log.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Removed and cleared videos can be restored for YTQUEUER_TRASH_RETENTION, 24h by default.
+	retention, err := envDuration("YTQUEUER_TRASH_RETENTION")
+	if err != nil {
+		log.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+
+	pls.SetTrashRetention(retention)
+
+	go metadata.Run(ctx, pls.UpdateMetadata)
+
+	// Schedules queue videos at set times. Runs missed while ytqueuer was stopped are handled by
+	// each schedule's missed policy once the scheduler starts.
+	schedules := ytqueuer.NewScheduler(logger, db, pls)
+	if err := schedules.LoadFromDB(); err != nil {
+		log.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+
+	go schedules.Run(ctx)
+
+	// Playback windows with standby set turn the TV off over CEC when they close.
+	go ytqueuer.NewWindowWatcher(logger, db, pls).Run(ctx)
+
+	// YouTube playlists are imported through the Data API when YTQUEUER_YOUTUBE_API_KEY is set.
+	// Otherwise the public playlist feed is used, which only lists the first 15 videos.
+	var fetcher ytqueuer.PlaylistFetcher = ytqueuer.NewYouTubeFeedFetcher("")
+	if key := os.Getenv("YTQUEUER_YOUTUBE_API_KEY"); key != "" {
+		fetcher = ytqueuer.NewYouTubeAPIFetcher(key, os.Getenv("YTQUEUER_YOUTUBE_API_URL"))
+	}
 
 	/*
 		sigs := make(chan os.Signal, 1)
@@ -149,7 +213,8 @@ func main() {
 		}()
 	*/
 
-	if err := start(logger, ctx, addr, port, certFile, keyFile, pls, db); err != nil {
+	err = start(logger, ctx, addr, port, certFile, keyFile, pls, events, schedules, cache, fetcher, db)
+	if err != nil {
 		log.Println(err)
 		deleteLock(logger)
 		os.Exit(1)
@@ -172,6 +237,10 @@ func parseArgs(logger *log.Logger) {
 	case "stop":
 		stop(logger)
 		os.Exit(0)
+	case "migrate-status":
+		migrateStatus(logger, false)
+	case "migrate-dry-run":
+		migrateStatus(logger, true)
 	case "start":
 		return
 	default:
@@ -348,7 +417,23 @@ func createCertFiles(certFile, keyFile string) (string, string, error) {
 	return certFile, keyFile, nil
 }
 
-func initDB() (*ytqueuer.SqliteDB, error) {
+// envDuration reads a duration such as 720h from the environment variable. It returns 0 if the
+// variable is not set.
+func envDuration(name string) (time.Duration, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return d, nil
+}
+
+func openDB() (*ytqueuer.SqliteDB, error) {
 	db, err := ytqueuer.NewSqliteDB("ytqueuer.db")
 	if err != nil {
 		return nil, err
@@ -359,10 +444,71 @@ func initDB() (*ytqueuer.SqliteDB, error) {
 		return nil, err
 	}
 
+	return db, nil
+}
+
+func initDB() (*ytqueuer.SqliteDB, error) {
+	db, err := openDB()
+	if err != nil {
+		return nil, err
+	}
+
 	err = db.Migrate()
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
+
+// migrateStatus prints the state of each database migration and exits. If dryRun is true the
+// pending migrations are applied and rolled back to check that they will succeed.
+func migrateStatus(logger *log.Logger, dryRun bool) {
+	db, err := openDB()
+	if err != nil {
+		logger.Printf("error: %v\n", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	var status []ytqueuer.MigrationStatus
+	if dryRun {
+		status, err = db.MigrateDryRun()
+	} else {
+		status, err = db.MigrationStatus()
+	}
+
+	if err != nil {
+		logger.Printf("error: %v\n", err)
+		db.Close()
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, m := range status {
+		state := "pending"
+		switch {
+		case m.Err != nil:
+			state = fmt.Sprintf("failed: %v", m.Err)
+			failed = true
+		case dryRun:
+			state = "ok"
+		case m.Applied:
+			state = "applied " + m.AppliedAt.Format(time.RFC3339)
+		}
+
+		logger.Printf("%3d %-55s %s\n", m.Version, m.Description, state)
+	}
+
+	if dryRun && len(status) == 0 {
+		logger.Println("no pending migrations")
+	}
+
+	db.Close()
+	if failed {
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
@@ -132,6 +132,14 @@ function addElements(parent, children) {
         return parent;
 }
 
+// escapeHTML makes text safe to insert into HTML, such as video titles which come from users or
+// imported files.
+function escapeHTML(text) {
+        const e = document.createElement('div');
+        e.textContent = text ?? "";
+        return e.innerHTML.replaceAll('"', '&quot;');
+}
+
 // newClassList returns a classList object. If classes is an array, it adds them to the classList.
 function newClassList(classes) {
         const e = document.createElement('div');
@@ -24,6 +24,7 @@ let cec = "";
 let waitingForPlaylists = false;
 let playlists = [];
 let playlist = [];
+let playlistEvents = null;
 
 function retryPlaylistsWatcher() {
         waitingForPlaylists = false;
@@ -58,20 +59,15 @@ const playlistsWatcher = async () => {
         }
 }
 
+// The server parses the URL so any YouTube link format, including timestamps, can be added.
 const getClipboardData = async () => {
         try {
-                const text = await navigator.clipboard.readText();
-                if (!text.includes('youtube.com/watch?v=')) {
-                        throw new Error('Invalid URL in clipboard.');
+                const text = (await navigator.clipboard.readText()).trim();
+                if (text === '') {
+                        throw new Error('Clipboard is empty.');
                 }
 
-                id = text.split("v=")[1];
-                if (text.includes('&')) {
-                        parts = id.split('&');
-                        return parts[0];
-                }
-
-                return id;
+                return text;
         } catch(err) {
                 // This is not an axios call so we can't use handleFailure().
                 log(`Failed to get data from clipboard: '${err}'`);
@@ -102,12 +98,110 @@ const getPlaylist = async () => {
                         return
                 }
         
-                showPlaylist(resp.data);
+                const q = resp.status === 200 ? resp.data : null;
+                showPlaylist(q ? q.videos : null);
+                showRuntime(q);
+                getCapacity();
+                if (!q) {
+                        getFallback();
+                }
         } catch(err) {
                 handleFailure(`Failed to get playlist for '${currentPlaylist.name}'`, err);
         }
 }
 
+// Show the fallback video playing while the queue is empty so it can be skipped.
+const getFallback = async () => {
+        try {
+                const resp = await axios.get(`/playlists/${currentPlaylist.id}/fallback`);
+                const v = resp.data.playing;
+                if (!v) {
+                        return
+                }
+
+                playlistDiv.innerHTML =
+`<ul class="pt-3 bg-neutral-600 rounded-xl"><li>
+        <div class="flex flex-row justify-between items-center pb-3">
+                <div class="flex flex-row">
+                        <div><img src="${escapeHTML(v.thumbnail_url || `https://i.ytimg.com/vi/${v.video_id}/hqdefault.jpg`)}" style="width:120px;height:90px"></div>
+                        <div class="flex flex-col pl-6">
+                                <div>${escapeHTML(v.title || v.video_id)}</div>
+                                <div>${escapeHTML(v.author_name)}</div>
+                                <div class="text-neutral-400">playing from ${escapeHTML(resp.data.source)} while the queue is empty</div>
+                        </div>
+                </div>
+                <div class="flex flex-row items-center">
+                        <button type="button" class="material-symbols-outlined text-5xl p-3 pl-6 hover:text-secondary-base" title="Skip Video" onClick="removeVideo(${v.item_id})">skip_next</button>
+                </div>
+        </div>
+</li></ul>`;
+        } catch(err) {
+                handleFailure('Failed to get fallback video', err);
+        }
+}
+
+// Show how many more videos the queue will take from us. Limits which are not set are left out.
+const getCapacity = async () => {
+        const capacity = document.getElementById('capacity');
+        try {
+                const resp = await axios.get(`/playlists/${currentPlaylist.id}/limits`);
+                const c = resp.data;
+                const parts = [];
+                if (c.remaining_for_submitter !== null) {
+                        parts.push(`${c.remaining_for_submitter} more from you`);
+                } else if (c.remaining_length !== null) {
+                        parts.push(`${c.remaining_length} more videos`);
+                }
+
+                if (c.remaining_duration_seconds !== null) {
+                        parts.push(`${Math.floor(c.remaining_duration_seconds / 60)} min left`);
+                }
+
+                capacity.innerText = parts.join(', ');
+        } catch(err) {
+                capacity.innerText = '';
+        }
+}
+
+// Show roughly when each video will start and how long the queue has left to play. Videos after
+// one whose length isn't known have no ETA.
+function showRuntime(q) {
+        const runtime = document.getElementById('runtime');
+        if (!q || q.videos.length === 0) {
+                runtime.innerText = '';
+                return
+        }
+
+        q.videos.forEach((v, i) => {
+                const eta = document.getElementById(`eta-${v.item_id}`);
+                if (eta === null || i === 0 || v.starts_in_seconds === null) {
+                        return
+                }
+
+                eta.innerText = `starts in ~${Math.round(v.starts_in_seconds / 60)} min`;
+        });
+
+        let total = `${Math.round(q.total_seconds / 60)} min of video queued`;
+        if (q.unknown > 0) {
+                total += ` (+${q.unknown} of unknown length)`;
+        }
+
+        runtime.innerText = total;
+}
+
+// Refresh the playlist whenever the server reports a change, such as a video's title arriving after
+// it was added.
+function watchPlaylist() {
+        if (playlistEvents !== null) {
+                playlistEvents.close();
+        }
+
+        playlistEvents = new EventSource(`/playlists/${currentPlaylist.id}/events`);
+        playlistEvents.addEventListener('playlist_updated', () => {
+                getPlaylist();
+        });
+}
+
 // Log a message if a playlist is not selected and return false. Otherwise, return true.
 function IsPlaylistSelected() {
         if (currentPlaylist === null || currentPlaylist === "" || currentPlaylist === "{}") {
@@ -124,12 +218,14 @@ const addVideo = async () => {
                         return
                 }
 
-                const videoID = await getClipboardData();
-                if (!videoID) {
+                const videoURL = await getClipboardData();
+                if (!videoURL) {
                         return;
                 }
         
-                const resp = await axios.post(`/playlists/${currentPlaylist.id}/${videoID}`);
+                const resp = await axios.post(`/playlists/${currentPlaylist.id}`, null, {
+                        params: { url: videoURL },
+                });
                 log(resp.data.message);
                 getPlaylist();
         } catch(err) {
@@ -143,12 +239,14 @@ const addNext = async () => {
                         return
                 }
 
-                const videoID = await getClipboardData();
-                if (!videoID) {
+                const videoURL = await getClipboardData();
+                if (!videoURL) {
                         return;
                 }
         
-                const resp = await axios.post(`/playlists/${currentPlaylist.id}/${videoID}/next`);
+                const resp = await axios.post(`/playlists/${currentPlaylist.id}`, null, {
+                        params: { url: videoURL, next: true },
+                });
                 log(resp.data.message);
                 getPlaylist();
         } catch(err) {
@@ -156,13 +254,13 @@ const addNext = async () => {
         }
 }
 
-const removeVideo = async (vid) => {
+const removeVideo = async (itemID) => {
         try {
                 if (!IsPlaylistSelected()) {
                         return
                 }
 
-                const resp = await axios.delete(`/playlists/${currentPlaylist.id}/${vid}`);
+                const resp = await axios.delete(`/playlists/${currentPlaylist.id}/items/${itemID}`);
                 if (resp.status !== 204) {
                         log('failed to remove video:' + resp.data.message);
                         return
@@ -189,11 +287,42 @@ const clearPlaylist = async () => {
         
                 log("playlist cleared");
                 showPlaylist();
+                showRuntime();
         } catch(err) {
                 handleFailure('Failed to clear playlist', err);
         }
 }
 
+const voteVideo = async (itemID, vote) => {
+        try {
+                if (!IsPlaylistSelected()) {
+                        return
+                }
+
+                const resp = await axios.put(`/playlists/${currentPlaylist.id}/items/${itemID}/vote`, null, {
+                        params: { vote: vote },
+                });
+                log(resp.data.message);
+                getPlaylist();
+        } catch(err) {
+                handleFailure('Failed to vote on video', err);
+        }
+}
+
+const undoRemove = async () => {
+        try {
+                if (!IsPlaylistSelected()) {
+                        return
+                }
+
+                const resp = await axios.post(`/playlists/${currentPlaylist.id}/undo`);
+                log(resp.data.message);
+                getPlaylist();
+        } catch(err) {
+                handleFailure('Failed to undo', err);
+        }
+}
+
 function playlistSelected() {
         if (currentPlaylist === null || currentPlaylist === "" || currentPlaylist.id === "") {
                 return false
@@ -223,13 +352,19 @@ function showPlaylist(q) {
 `<li>
         <div class="flex flex-row justify-between items-center pb-3">
                 <div class="flex flex-row">
-                        <div><img src="${v.thumbnail_url}" style="width:120px;height:90px"></div>
+                        <div><img src="${escapeHTML(v.thumbnail_url || `https://i.ytimg.com/vi/${v.video_id}/hqdefault.jpg`)}" style="width:120px;height:90px"></div>
                         <div class="flex flex-col pl-6">
-                                <div>${v.title}</div>
-                                <div>${v.author_name}</div>
+                                <div>${escapeHTML(v.title || v.video_id)}</div>
+                                <div>${escapeHTML(v.author_name)}</div>
+                                <div id="eta-${v.item_id}" class="text-neutral-400"></div>
                         </div>
                 </div>
-                <div><button type="button" class="material-symbols-outlined text-5xl p-3 pl-6 hover:text-secondary-base" title="Remove Video" onClick="removeVideo('${v.video_id}')">playlist_remove</button></div>
+                <div class="flex flex-row items-center">
+                        <button type="button" class="material-symbols-outlined text-4xl p-3 hover:text-secondary-base" title="Upvote" onClick="voteVideo(${v.item_id}, 'up')">thumb_up</button>
+                        <div>${v.upvotes - v.downvotes}</div>
+                        <button type="button" class="material-symbols-outlined text-4xl p-3 hover:text-secondary-base" title="Downvote" onClick="voteVideo(${v.item_id}, 'down')">thumb_down</button>
+                        <button type="button" class="material-symbols-outlined text-5xl p-3 pl-6 hover:text-secondary-base" title="Remove Video" onClick="removeVideo(${v.item_id})">playlist_remove</button>
+                </div>
         </div>
 </li>`;
         });
@@ -283,6 +418,7 @@ function selectPBC(e) {
 
         setCookie(COOKIE_NAME, currentPlaylist);
         getPlaylist();
+        watchPlaylist();
         updatePowerSettingsMenu();
 }
 
@@ -703,6 +839,7 @@ const startup = async () => {
 
         pbcMenu.value = currentPlaylist.id;
         await getPlaylist();
+        watchPlaylist();
         if (playlists === null || playlists === "" || playlists.length === 0) {
                 currentPlaylist = "";
         }
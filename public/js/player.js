@@ -84,27 +84,78 @@ const getPlaybackClientList = async () => {
 
 function onPlayerReady(event) {
         playNextVideo();
+        window.setInterval(checkQueuedVideo, 5000);
 }
 
 function onPlayerStateChange(event) {
         if (event.data == YT.PlayerState.ENDED) {
+                // Looping videos stay at the front of the queue so the server hands them back until
+                // they are removed.
                 playNextVideo();
+                return
+        }
+
+        if (event.data == YT.PlayerState.PLAYING) {
+                reportDuration(currentVideo);
+        }
+}
+
+// reportDuration tells the server how long the video is the first time it plays, if the server
+// doesn't know already.
+const reportDuration = async (video) => {
+        if (!video || !video.item_id || video.duration_seconds > 0) {
+                return
+        }
+
+        const secs = Math.round(player.getDuration());
+        if (!secs) {
+                return
+        }
+
+        video.duration_seconds = secs;
+        try {
+                await axios.put(
+                        `/playlists/${pbc.id}/items/${video.item_id}/duration`,
+                        null,
+                        { params: { seconds: secs } },
+                );
+        } catch(err) {
+                handleFailure('Failed to report video duration', err);
+        }
+}
+
+// loadVideo plays the video from its start time. If the video has an end time the player stops
+// there and reports the video as ended.
+function loadVideo(video) {
+        const opts = { videoId: video.video_id, startSeconds: video.start_seconds };
+        if (video.end_seconds > 0) {
+                opts.endSeconds = video.end_seconds;
         }
+
+        player.loadVideoById(opts);
 }
 
-function retryNextVideo() {
+function retryNextVideo(token) {
         waitingForNextVideo = false;
-        getNextVideo();
+        getNextVideo(token);
 }
 
-const getNextVideo = async () => {
+// getNextVideo asks the server to finish the current video and hand back the next one in a single
+// request. The token lets the server recognize a retry so a request that failed on the way back
+// can't skip a second video.
+const getNextVideo = async (token = crypto.randomUUID()) => {
         try {
                 if (waitingForNextVideo) {
                         return
                 }
 
                 waitingForNextVideo = false;
-                const resp = await axios.get(`/playlists/${pbc.id}/next`);
+                const params = { token: token };
+                if (currentVideo !== null && currentVideo.item_id) {
+                        params.current = currentVideo.item_id;
+                }
+
+                const resp = await axios.post(`/playlists/${pbc.id}/advance`, null, { params: params });
                 // If we do not get a 200 status code then we'll wait 2 seconds and try again.
                 if (resp.status !== 200) {
                         currentVideo = resp.status;
@@ -115,14 +166,18 @@ const getNextVideo = async () => {
                 }
         
                 currentVideo = resp.data;
-                player.loadVideoById(currentVideo.video_id, currentVideo.start_seconds);
+                loadVideo(currentVideo);
         } catch(err) {
-                currentVideo = err.status;
                 handleFailure('Failed to get next video', err);
+                if (err.status === 404) {
+                        currentVideo = err.status;
+                        return
+                }
+
                 // If we get an error then the service is probably down or there's some other
-                // issue. We'll wait 10 seconds and try again.
+                // issue. We'll wait 10 seconds and retry the same request.
                 waitingForNextVideo = true;
-                window.setTimeout(retryNextVideo, 10000);
+                window.setTimeout(() => retryNextVideo(token), 10000);
         }
 }
 
@@ -141,27 +196,31 @@ const peekNextVideo = async () => {
         }
 }
 
-const removeVideo = async (vid) => {
+// checkQueuedVideo switches to a queued video as soon as one is added while a fallback video is
+// playing. The server keeps handing back the same fallback video until something is queued or the
+// fallback video is skipped from a controller.
+const checkQueuedVideo = async () => {
+        if (!currentVideo || !currentVideo.fallback || waitingForNextVideo) {
+                return
+        }
+
         try {
-                const resp = await axios.delete(`/playlists/${pbc.id}/${vid}`);
-                if (resp.status !== 204) {
-                        log('failed to remove video:' + resp.data.message);
+                const resp = await axios.get(`/playlists/${pbc.id}/next`);
+                if (resp.status !== 200 || resp.data.item_id === currentVideo.item_id) {
                         return
                 }
+
+                currentVideo = resp.data;
+                loadVideo(currentVideo);
         } catch(err) {
-                handleFailure('Failed to remove video', err);
+                handleFailure('Failed to check for queued videos', err);
         }
 }
 
 const playNextVideo = async () => {
         try {
-                // If we have valid video data then remove the video from the playlist.
-                if (currentVideo !== null && currentVideo !== "" && currentVideo !== 204) {
-                        await removeVideo(currentVideo.video_id);
-                }
-
-                // Get the next video and play it. If there are no videos in the playlist then
-                // getNextVideo() will rerun itself until it gets one.
+                // Finish the current video, if there is one, and play the next. If there are no
+                // videos in the playlist then getNextVideo() will rerun itself until it gets one.
                 await getNextVideo();
                 // We should only get a 404 if the playback client is not found. Delete the cookie
                 // and run startup() to show the registration form.